@@ -2,16 +2,30 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
-	"github.com/joho/godotenv"
 	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
 	"github.com/ncostamagna/gocourse_enrollment/pkg/bootstrap"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/config"
 	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/health"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/pb"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/ratelimit"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/sdk"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
 
 	courseSdk "github.com/ncostamagna/go_course_sdk/course"
 	userSdk "github.com/ncostamagna/go_course_sdk/user"
@@ -19,53 +33,291 @@ import (
 
 func main() {
 
-	_ = godotenv.Load()
-	l := bootstrap.InitLogger()
-	db, err := bootstrap.DBConnection()
+	migrate := len(os.Args) > 1 && os.Args[1] == "migrate"
+
+	load := config.Load
+	if migrate {
+		load = config.LoadDatabase
+	}
+	cfg, err := load()
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	l, err := bootstrap.InitLogger(cfg.Log)
 	if err != nil {
-		l.Fatal(err)
+		log.Fatal(err)
+	}
+
+	if migrate {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			l.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run(cfg, l); err != nil {
+		l.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+// runMigrate handles "migrate up", "migrate down [steps]" and "migrate status".
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status")
+	}
+
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid steps '%s'", args[1])
+		}
+		steps = n
 	}
 
-	pagLimDef := os.Getenv("PAGINATOR_LIMIT_DEFAULT")
-	if pagLimDef == "" {
-		l.Fatal("paginator limit default is required")
+	if cfg.Database.Driver == "memory" {
+		return errors.New("the memory driver has no migrations")
+	}
+
+	cfg.Database.Migrate = false
+	db, err := bootstrap.DBConnection(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = bootstrap.CloseDB(db)
+	}()
+
+	status, err := bootstrap.Migrate(context.Background(), db, args[0], steps)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range status {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return w.Flush()
+}
+
+func run(cfg config.Config, l *slog.Logger) error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracer, err := bootstrap.InitTracer(ctx, cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracer(context.Background()); err != nil {
+			l.Error("shutting down tracer", "error", err)
+		}
+	}()
+
+	m := bootstrap.InitMetrics()
+
+	// db stays nil with the memory driver
+	var db *gorm.DB
+	var repo enrollment.Repository
+	if cfg.Database.Driver == "memory" {
+		l.Warn("using the in-memory repository, the enrollments are lost when the service stops")
+		repo = enrollment.NewMemoryRepo()
+	} else {
+		db, err = bootstrap.DBConnection(cfg.Database)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := bootstrap.CloseDB(db); err != nil {
+				l.Error("closing database", "error", err)
+			}
+		}()
+
+		replica, err := bootstrap.ReplicaConnection(cfg.Database)
+		if err != nil {
+			return err
+		}
+		opts := []enrollment.RepoOption{
+			enrollment.WithQueryTimeout(cfg.Database.QueryTimeout),
+			enrollment.WithSlowQueries(cfg.Database.SlowQueryThreshold, m.SlowQueries),
+		}
+		if replica != nil {
+			defer func() {
+				if err := bootstrap.CloseDB(replica); err != nil {
+					l.Error("closing read replica", "error", err)
+				}
+			}()
+			opts = append(opts, enrollment.WithReplica(enrollment.NewReplica(replica, cfg.Database.ReplicaRetry)))
+		}
+		repo = enrollment.NewRepo(db, l, opts...)
 	}
 
-	courseTrans := courseSdk.NewHttpClient(os.Getenv("API_COURSE_URL"), "")
-	userTrans := userSdk.NewHttpClient(os.Getenv("API_USER_URL"), "")
+	h, endpoints, checker := newServer(ctx, cfg, l, db, repo, m)
 
-	ctx := context.Background()
-	enrollRepo := enrollment.NewRepo(db, l)
-	enrollSrv := enrollment.NewService(l, userTrans, courseTrans, enrollRepo)
-	h := handler.NewEnrollmentHTTPServer(ctx, enrollment.MakeEndpoints(enrollSrv, enrollment.Config{LimPageDef: pagLimDef}))
-	port := os.Getenv("PORT")
-	address := fmt.Sprintf("127.0.0.1:%s", port)
+	address := fmt.Sprintf("127.0.0.1:%s", cfg.Port)
 	srv := &http.Server{
-		Handler:      accessControl(h),
+		Handler:      h,
 		Addr:         address,
 		WriteTimeout: 10 * time.Second,
 		ReadTimeout:  4 * time.Second,
 	}
 
-	errCh := make(chan error)
+	grpcAddress := fmt.Sprintf("127.0.0.1:%s", cfg.GRPCPort)
+	lis, err := net.Listen("tcp", grpcAddress)
+	if err != nil {
+		return err
+	}
+	grpcSrv := grpc.NewServer()
+	pb.RegisterEnrollmentServiceServer(grpcSrv, handler.NewEnrollmentGRPCServer(ctx, endpoints, handler.WithLogger(l)))
+
+	errCh := make(chan error, 2)
 
 	go func() {
-		l.Println("listen in ", address)
-		errCh <- srv.ListenAndServe()
+		l.Info("http server listening", "address", address)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
 	}()
 
-	err = <-errCh
-	if err != nil {
-		log.Fatal(err)
+	go func() {
+		l.Info("grpc server listening", "address", grpcAddress)
+		if err := grpcSrv.Serve(lis); err != nil {
+			errCh <- err
+		}
+	}()
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		l.Info("shutdown signal received")
+	case serveErr = <-errCh:
+		l.Error("server stopped", "error", serveErr)
+	}
+	stop()
+
+	// the readiness probe fails first so the load balancer stops sending traffic
+	// before the listeners are closed
+	checker.SetReady(false)
+	time.Sleep(cfg.Shutdown.DrainDelay)
+
+	return errors.Join(serveErr, shutdown(srv, grpcSrv, cfg.Shutdown.GracePeriod))
+}
+
+// newServer wires the service and returns the HTTP handler, the endpoints
+// shared with the gRPC server and the readiness checker. db is nil with the
+// memory driver.
+func newServer(ctx context.Context, cfg config.Config, l *slog.Logger, db *gorm.DB, repo enrollment.Repository, m enrollment.Metrics) (http.Handler, enrollment.Endpoints, *health.Checker) {
+	courseClient := sdk.NewCourseClient(cfg.API.CourseURL, cfg.API.Timeout)
+	userClient := sdk.NewUserClient(cfg.API.UserURL, cfg.API.Timeout)
+	courseTrans := enrollment.NewInstrumentingCourseTransport(courseClient, m.SdkLatency, m.SdkErrors)
+	userTrans := enrollment.NewInstrumentingUserTransport(userClient, m.SdkLatency, m.SdkErrors)
+
+	events := enrollment.NewBroker(cfg.Events.BufferSize, cfg.Events.SubscriberBuffer)
+
+	enrollRepo := enrollment.NewInstrumentingRepo(repo, m.RepoLatency, m.RepoErrors)
+	enrollSrv := enrollment.NewService(l, userTrans, courseTrans, enrollRepo,
+		enrollment.WithExpandConcurrency(cfg.API.ExpandConcurrency), enrollment.WithEvents(events))
+	endpoints := enrollment.TraceEndpoints(enrollment.InstrumentEndpoints(enrollment.MakeEndpoints(enrollSrv, enrollment.Config{LimPageDef: strconv.Itoa(cfg.PaginatorLimitDefault)}), m))
+
+	// the probes don't go through the instrumenting transports, they aren't
+	// calls of the service
+	checker := healthChecker(cfg.Health, db, userClient, courseClient)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/healthz", checker.LiveHandler())
+	mux.Handle("/readyz", checker.ReadyHandler())
+	opts := []handler.ServerOption{
+		handler.WithLogger(l), handler.WithRateLimits(rateLimits(cfg.RateLimit, l)),
+		handler.WithEventStream(handler.EventStream{Broker: events, Heartbeat: cfg.Events.Heartbeat}),
+	}
+	for version, d := range cfg.Versioning.Deprecations {
+		opts = append(opts, handler.WithDeprecation(version, handler.Deprecation{Since: d.Since, Sunset: d.Sunset}))
 	}
+	mux.Handle("/", handler.NewEnrollmentHTTPServer(ctx, endpoints, opts...))
+
+	return accessControl(mux), endpoints, checker
+}
 
+// shutdown drains the in-flight HTTP requests and gRPC calls within the grace period.
+func shutdown(srv *http.Server, grpcSrv *grpc.Server, gracePeriod time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	grpcDone := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(grpcDone)
+	}()
+
+	err := srv.Shutdown(ctx)
+
+	select {
+	case <-grpcDone:
+	case <-ctx.Done():
+		grpcSrv.Stop()
+		err = errors.Join(err, fmt.Errorf("grpc server: %w", ctx.Err()))
+	}
+
+	return err
+}
+
+func healthChecker(cfg config.Health, db *gorm.DB, userTrans userSdk.Transport, courseTrans courseSdk.Transport) *health.Checker {
+	checker := health.NewChecker(cfg.CheckTimeout, cfg.CacheTTL)
+	if db != nil {
+		checker.Add("database", health.DBCheck(db))
+	}
+	if cfg.CheckDependencies {
+		checker.Add("user_service", health.UserCheck(userTrans))
+		checker.Add("course_service", health.CourseCheck(courseTrans))
+	}
+
+	return checker
+}
+
+// rateLimits keeps the buckets in memory, so every instance applies the limits
+// on its own. The clients are told apart by their IP address only, the
+// service doesn't authenticate the X-API-Key and X-User-ID headers.
+func rateLimits(cfg config.RateLimit, l *slog.Logger) handler.RateLimits {
+	if !cfg.Enabled {
+		return handler.RateLimits{}
+	}
+
+	routes := make(map[string]ratelimit.Limit, len(cfg.Routes))
+	for name, l := range cfg.Routes {
+		routes[name] = ratelimit.Limit(l)
+	}
+
+	// ParseProxies can't fail, the config was validated
+	proxies, _ := ratelimit.ParseProxies(cfg.TrustedProxies)
+	if len(proxies) == 0 {
+		l.Warn("rate limiting without trusted proxies, the clients behind a proxy share its limits")
+	}
+
+	return handler.RateLimits{
+		Store:  ratelimit.NewMemoryStore(),
+		Key:    ratelimit.ProxyClientKey(proxies),
+		Read:   ratelimit.Limit{Requests: cfg.ReadRequests, Period: cfg.ReadPeriod, Burst: cfg.ReadBurst},
+		Write:  ratelimit.Limit{Requests: cfg.WriteRequests, Period: cfg.WritePeriod, Burst: cfg.WriteBurst},
+		Routes: routes,
+	}
 }
 
 func accessControl(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, OPTIONS, HEAD, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Modified-Since,Keep-Alive,Origin,User-Agent,X-Requested-With")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition,Deprecation,ETag,Last-Modified,Link,Retry-After,Sunset,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset,X-Request-ID,X-Trace-ID")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept,Authorization,Cache-Control,Content-Type,DNT,If-Modified-Since,If-None-Match,Keep-Alive,Last-Event-ID,Origin,User-Agent,X-Requested-With")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/bootstrap"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/config"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/fakeapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ids of the users and courses of the fake services
+const (
+	user1   = "6a1e2b3c-4d5e-4f60-8a7b-9c0d1e2f3a4b"
+	user2   = "7b2f3c4d-5e6f-4a71-9b8c-0d1e2f3a4b5c"
+	user9   = "8c3a4d5e-6f7a-4b82-8c9d-1e2f3a4b5c6d"
+	course1 = "9d4b5e6f-7a8b-4c93-9d0e-2f3a4b5c6d7e"
+	course2 = "ae5c6f7a-8b9c-4da4-8e1f-3a4b5c6d7e8f"
+)
+
+type body struct {
+	Status  int                    `json:"status"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Fields  enrollment.FieldErrors `json:"fields"`
+	Data    json.RawMessage        `json:"data"`
+	Meta    map[string]any         `json:"meta"`
+}
+
+// newE2E boots the whole HTTP stack on a SQLite database with the real
+// migrations, calling the fake user and course services.
+func newE2E(t *testing.T) (*httptest.Server, *fakeapi.Server) {
+	t.Helper()
+
+	api := fakeapi.New()
+	t.Cleanup(api.Close)
+
+	cfg, err := config.LoadFrom("", func(key string) (string, bool) {
+		env := map[string]string{
+			"DATABASE_DRIVER":           "sqlite",
+			"DATABASE_NAME":             filepath.Join(t.TempDir(), "enrollments.db"),
+			"DATABASE_MIGRATE":          "true",
+			"API_USER_URL":              api.URL,
+			"API_COURSE_URL":            api.URL,
+			"HEALTH_CHECK_DEPENDENCIES": "true",
+			"HEALTH_CACHE_TTL":          "0s",
+		}
+		v, ok := env[key]
+		return v, ok
+	})
+	require.NoError(t, err)
+
+	db, err := bootstrap.DBConnection(cfg.Database)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bootstrap.CloseDB(db) })
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := enrollment.Metrics{
+		RequestCount:   discard.NewCounter(),
+		RequestLatency: discard.NewHistogram(),
+		RepoLatency:    discard.NewHistogram(),
+		RepoErrors:     discard.NewCounter(),
+		SdkLatency:     discard.NewHistogram(),
+		SdkErrors:      discard.NewCounter(),
+	}
+
+	h, _, _ := newServer(context.Background(), cfg, l, db, enrollment.NewRepo(db, l), m)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	return srv, api
+}
+
+func TestRateLimits(t *testing.T) {
+	cfg := config.RateLimit{
+		Enabled:      true,
+		ReadRequests: 1, ReadPeriod: time.Minute,
+		WriteRequests: 1, WritePeriod: time.Minute,
+		TrustedProxies: "127.0.0.1,::1",
+	}
+
+	t.Run("should tell apart the clients of the local proxy", func(t *testing.T) {
+		var logs bytes.Buffer
+		rl := rateLimits(cfg, slog.New(slog.NewTextHandler(&logs, nil)))
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/enrollments", nil)
+		req.RemoteAddr = "127.0.0.1:41000"
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		assert.Equal(t, "ip:198.51.100.7", rl.Key(req))
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("should warn without trusted proxies", func(t *testing.T) {
+		cfg := cfg
+		cfg.TrustedProxies = ""
+		var logs bytes.Buffer
+		rateLimits(cfg, slog.New(slog.NewTextHandler(&logs, nil)))
+
+		assert.Contains(t, logs.String(), "rate limiting without trusted proxies")
+	})
+}
+
+func do(t *testing.T, method, url string, payload any) (int, body) {
+	t.Helper()
+
+	var r io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		require.NoError(t, err)
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, url, r)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var b body
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&b))
+	return resp.StatusCode, b
+}
+
+func TestEndToEnd(t *testing.T) {
+	srv, api := newE2E(t)
+
+	api.AddUsers(domain.User{ID: user1, FirstName: "Nahuel"}, domain.User{ID: user2, FirstName: "Ana"})
+	api.AddCourses(domain.Course{ID: course1, Name: "Go"}, domain.Course{ID: course2, Name: "Kubernetes"})
+
+	var created domain.Enrollment
+
+	t.Run("should create an enrollment", func(t *testing.T) {
+		code, b := do(t, http.MethodPost, srv.URL+"/enrollments", map[string]string{"user_id": user1, "course_id": course1})
+		require.Equal(t, http.StatusCreated, code, b.Message)
+
+		require.NoError(t, json.Unmarshal(b.Data, &created))
+		assert.NotEmpty(t, created.ID)
+		assert.Equal(t, user1, created.UserID)
+		assert.Equal(t, course1, created.CourseID)
+		assert.Equal(t, "P", created.Status)
+	})
+
+	t.Run("should return not found when the user doesn't exist", func(t *testing.T) {
+		code, b := do(t, http.MethodPost, srv.URL+"/enrollments", map[string]string{"user_id": user9, "course_id": course1})
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, enrollment.CodeUserNotFound, b.Code)
+		assert.Equal(t, "user '"+user9+"' doesn't exist", b.Message)
+	})
+
+	t.Run("should return conflict when the user is already enrolled", func(t *testing.T) {
+		code, b := do(t, http.MethodPost, srv.URL+"/enrollments", map[string]string{"user_id": user1, "course_id": course1})
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, enrollment.CodeAlreadyEnrolled, b.Code)
+	})
+
+	t.Run("should fail when the course service fails", func(t *testing.T) {
+		t.Cleanup(api.ClearFaults)
+		api.SetFault(fakeapi.Courses, "", fakeapi.Fault{StatusCode: http.StatusInternalServerError, Message: "course service down"})
+
+		code, b := do(t, http.MethodPost, srv.URL+"/enrollments", map[string]string{"user_id": user1, "course_id": course2})
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, enrollment.CodeDependencyUnavailable, b.Code)
+		assert.Equal(t, "the course service is unavailable, retry later", b.Message)
+	})
+
+	t.Run("should wait for a slow course service", func(t *testing.T) {
+		t.Cleanup(api.ClearFaults)
+		api.SetFault(fakeapi.Courses, course2, fakeapi.Fault{Latency: 100 * time.Millisecond})
+
+		start := time.Now()
+		code, _ := do(t, http.MethodPost, srv.URL+"/enrollments", map[string]string{"user_id": user2, "course_id": course2})
+		assert.Equal(t, http.StatusCreated, code)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("should list the enrollments of a user", func(t *testing.T) {
+		code, b := do(t, http.MethodGet, srv.URL+"/enrollments?user_id="+user1, nil)
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		var enrollments []domain.Enrollment
+		require.NoError(t, json.Unmarshal(b.Data, &enrollments))
+		require.Len(t, enrollments, 1)
+		assert.Equal(t, created.ID, enrollments[0].ID)
+		assert.EqualValues(t, 1, b.Meta["total_count"])
+	})
+
+	t.Run("should only return the selected fields", func(t *testing.T) {
+		code, b := do(t, http.MethodGet, srv.URL+"/enrollments?user_id="+user1+"&fields=id,course_id,status", nil)
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		var enrollments []map[string]any
+		require.NoError(t, json.Unmarshal(b.Data, &enrollments))
+		assert.Equal(t, []map[string]any{{"id": created.ID, "course_id": course1, "status": "P"}}, enrollments)
+	})
+
+	t.Run("should update the status", func(t *testing.T) {
+		code, b := do(t, http.MethodPatch, srv.URL+"/enrollments/"+created.ID, map[string]string{"status": "A"})
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		_, b = do(t, http.MethodGet, srv.URL+"/enrollments?user_id="+user1, nil)
+		var enrollments []domain.Enrollment
+		require.NoError(t, json.Unmarshal(b.Data, &enrollments))
+		assert.Equal(t, "A", enrollments[0].Status)
+	})
+
+	t.Run("should return not found when updating a missing enrollment", func(t *testing.T) {
+		code, _ := do(t, http.MethodPatch, srv.URL+"/enrollments/0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e", map[string]string{"status": "A"})
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("should list every invalid field of an update", func(t *testing.T) {
+		code, b := do(t, http.MethodPatch, srv.URL+"/enrollments/missing", map[string]string{"status": "X"})
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, enrollment.CodeInvalidRequest, b.Code)
+		assert.Equal(t, enrollment.FieldErrors{
+			{Field: "id", Message: "must be a UUID"},
+			{Field: "status", Message: "must be P, A, S, I or C"},
+		}, b.Fields)
+	})
+
+	t.Run("should reject an unknown field", func(t *testing.T) {
+		code, b := do(t, http.MethodPatch, srv.URL+"/enrollments/"+created.ID, map[string]string{"state": "A"})
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, enrollment.FieldErrors{{Field: "state", Message: "isn't a known field"}}, b.Fields)
+	})
+
+	t.Run("should return not modified until the enrollments change", func(t *testing.T) {
+		get := func(etag string) *http.Response {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/enrollments?user_id="+user1, nil)
+			require.NoError(t, err)
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp
+		}
+
+		first := get("")
+		require.Equal(t, http.StatusOK, first.StatusCode)
+		etag := first.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+		assert.NotEmpty(t, first.Header.Get("Last-Modified"))
+
+		assert.Equal(t, http.StatusNotModified, get(etag).StatusCode)
+
+		code, _ := do(t, http.MethodPatch, srv.URL+"/enrollments/"+created.ID, map[string]string{"status": "S"})
+		require.Equal(t, http.StatusOK, code)
+		t.Cleanup(func() { do(t, http.MethodPatch, srv.URL+"/enrollments/"+created.ID, map[string]string{"status": "A"}) })
+
+		resp := get(etag)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+	})
+
+	t.Run("should be ready while the dependencies answer", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/readyz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should not be ready when the user service fails", func(t *testing.T) {
+		t.Cleanup(api.ClearFaults)
+		api.SetFault(fakeapi.Users, "", fakeapi.Fault{StatusCode: http.StatusInternalServerError})
+
+		resp, err := http.Get(srv.URL + "/readyz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	})
+
+	t.Run("should get an enrollment with its user", func(t *testing.T) {
+		code, b := do(t, http.MethodGet, srv.URL+"/enrollments/"+created.ID+"?expand=user", nil)
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		var e enrollment.ExpandedEnrollment
+		require.NoError(t, json.Unmarshal(b.Data, &e))
+		assert.Equal(t, created.ID, e.ID)
+		require.NotNil(t, e.User)
+		assert.Equal(t, "Nahuel", e.User.FirstName)
+		assert.Nil(t, e.Course)
+	})
+
+	t.Run("should expand each user and course once and mark the failures", func(t *testing.T) {
+		code, _ := do(t, http.MethodPost, srv.URL+"/enrollments", map[string]string{"user_id": user1, "course_id": course2})
+		require.Equal(t, http.StatusCreated, code)
+
+		t.Cleanup(api.ClearFaults)
+		api.SetFault(fakeapi.Courses, course2, fakeapi.Fault{StatusCode: http.StatusInternalServerError, Message: "course service down"})
+
+		users, courses := api.Requests(fakeapi.Users, user1), api.Requests(fakeapi.Courses, course1)
+		code, b := do(t, http.MethodGet, srv.URL+"/enrollments?user_id="+user1+"&expand=user,course", nil)
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		var enrollments []enrollment.ExpandedEnrollment
+		require.NoError(t, json.Unmarshal(b.Data, &enrollments))
+		require.Len(t, enrollments, 2)
+		assert.Equal(t, users+1, api.Requests(fakeapi.Users, user1))
+		assert.Equal(t, courses+1, api.Requests(fakeapi.Courses, course1))
+
+		for _, e := range enrollments {
+			assert.Equal(t, "Nahuel", e.User.FirstName)
+			switch e.CourseID {
+			case course1:
+				assert.Equal(t, "Go", e.Course.Name)
+				assert.Nil(t, e.CourseError)
+			case course2:
+				assert.Nil(t, e.Course)
+				assert.Equal(t, &enrollment.ExpandError{
+					Status:  http.StatusServiceUnavailable,
+					Code:    enrollment.CodeDependencyUnavailable,
+					Message: "the course service is unavailable, retry later",
+				}, e.CourseError)
+			}
+		}
+	})
+
+	t.Run("should reject an unknown expand", func(t *testing.T) {
+		code, _ := do(t, http.MethodGet, srv.URL+"/enrollments?expand=teacher", nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should stream the enrollments created", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/enrollments/stream?user_id="+user2, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		code, b := do(t, http.MethodPost, srv.URL+"/enrollments", map[string]string{"user_id": user2, "course_id": course1})
+		require.Equal(t, http.StatusCreated, code, b.Message)
+		var enroll domain.Enrollment
+		require.NoError(t, json.Unmarshal(b.Data, &enroll))
+
+		lines := bufio.NewScanner(resp.Body)
+		var event, data string
+		for lines.Scan() && lines.Text() != "" {
+			field, value, _ := strings.Cut(lines.Text(), ": ")
+			switch field {
+			case "event":
+				event = value
+			case "data":
+				data = value
+			}
+		}
+
+		assert.Equal(t, "enrollment.created", event)
+		var got domain.Enrollment
+		require.NoError(t, json.Unmarshal([]byte(data), &got))
+		assert.Equal(t, enroll.ID, got.ID)
+	})
+}
+
+func TestEndToEndProgress(t *testing.T) {
+	srv, api := newE2E(t)
+
+	api.AddUsers(domain.User{ID: user1, FirstName: "Nahuel"})
+	api.AddCourses(domain.Course{ID: course1, Name: "Go"})
+
+	code, b := do(t, http.MethodPost, srv.URL+"/v1/enrollments", map[string]string{"user_id": user1, "course_id": course1})
+	require.Equal(t, http.StatusCreated, code, b.Message)
+	var created domain.Enrollment
+	require.NoError(t, json.Unmarshal(b.Data, &created))
+
+	progressURL := srv.URL + "/v1/enrollments/" + created.ID + "/progress"
+	lesson1, lesson2 := "1f2e3d4c-5b6a-4978-8695-a4b3c2d1e0f9", "2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d"
+
+	t.Run("should return an empty progress", func(t *testing.T) {
+		code, b := do(t, http.MethodGet, progressURL, nil)
+		require.Equal(t, http.StatusOK, code, b.Message)
+		assert.JSONEq(t, `{"enrollment_id":"`+created.ID+`","status":"P","percent":0,"completed_lessons":[],"total_lessons":0}`, string(b.Data))
+	})
+
+	t.Run("should record a completed lesson", func(t *testing.T) {
+		code, b := do(t, http.MethodPost, progressURL, map[string]any{"lesson_id": lesson1, "total_lessons": 2})
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		var p enrollment.Progress
+		require.NoError(t, json.Unmarshal(b.Data, &p))
+		assert.Equal(t, 50, p.Percent)
+		assert.Equal(t, []string{lesson1}, p.CompletedLessons)
+		assert.Equal(t, "P", p.Status)
+		assert.NotNil(t, p.LastActivityAt)
+	})
+
+	t.Run("should reject another total of lessons", func(t *testing.T) {
+		code, b := do(t, http.MethodPost, progressURL, map[string]any{"lesson_id": lesson2, "total_lessons": 1})
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Equal(t, enrollment.CodeTotalLessonsChanged, b.Code)
+	})
+
+	t.Run("should complete the enrollment with the last lesson", func(t *testing.T) {
+		code, b := do(t, http.MethodPost, progressURL, map[string]any{"lesson_id": lesson2, "total_lessons": 2})
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		_, b = do(t, http.MethodGet, srv.URL+"/v1/enrollments/"+created.ID, nil)
+		var e domain.Enrollment
+		require.NoError(t, json.Unmarshal(b.Data, &e))
+		assert.Equal(t, enrollment.StatusCompleted, e.Status)
+
+		_, b = do(t, http.MethodGet, progressURL, nil)
+		var p enrollment.Progress
+		require.NoError(t, json.Unmarshal(b.Data, &p))
+		assert.Equal(t, 100, p.Percent)
+		assert.Equal(t, []string{lesson1, lesson2}, p.CompletedLessons)
+	})
+
+	t.Run("should list every invalid field of a progress event", func(t *testing.T) {
+		code, b := do(t, http.MethodPost, progressURL, map[string]any{"lesson_id": "intro"})
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, enrollment.FieldErrors{
+			{Field: "lesson_id", Message: "must be a UUID"},
+			{Field: "total_lessons", Message: "must be at least 1"},
+		}, b.Fields)
+	})
+
+	t.Run("should return not found for a missing enrollment", func(t *testing.T) {
+		code, b := do(t, http.MethodGet, srv.URL+"/v1/enrollments/0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e/progress", nil)
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, enrollment.CodeEnrollmentNotFound, b.Code)
+	})
+}
+
+func TestEndToEndCertificates(t *testing.T) {
+	srv, api := newE2E(t)
+
+	api.AddUsers(domain.User{ID: user1, FirstName: "Nahuel", LastName: "Costamagna"})
+	api.AddCourses(domain.Course{ID: course1, Name: "Go"})
+
+	code, b := do(t, http.MethodPost, srv.URL+"/v1/enrollments", map[string]string{"user_id": user1, "course_id": course1})
+	require.Equal(t, http.StatusCreated, code, b.Message)
+	var created domain.Enrollment
+	require.NoError(t, json.Unmarshal(b.Data, &created))
+	certificateURL := srv.URL + "/v1/enrollments/" + created.ID + "/certificate"
+
+	t.Run("should not have a certificate before it's completed", func(t *testing.T) {
+		code, b := do(t, http.MethodGet, certificateURL, nil)
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, enrollment.CodeCertificateNotFound, b.Code)
+	})
+
+	var cert enrollment.Certificate
+
+	t.Run("should issue the certificate when it's completed", func(t *testing.T) {
+		code, b := do(t, http.MethodPatch, srv.URL+"/v1/enrollments/"+created.ID, map[string]string{"status": enrollment.StatusCompleted})
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		code, b = do(t, http.MethodGet, certificateURL, nil)
+		require.Equal(t, http.StatusOK, code, b.Message)
+		require.NoError(t, json.Unmarshal(b.Data, &cert))
+		assert.Equal(t, "Nahuel Costamagna", cert.StudentName)
+		assert.Equal(t, "Go", cert.CourseTitle)
+		assert.NotEmpty(t, cert.Code)
+	})
+
+	t.Run("should download the certificate as a PDF", func(t *testing.T) {
+		resp, err := http.Get(certificateURL + "/pdf")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `attachment; filename=certificate-`+cert.Code+`.pdf`, resp.Header.Get("Content-Disposition"))
+		assert.True(t, bytes.HasPrefix(content, []byte("%PDF-")))
+		assert.Contains(t, string(content), "(Nahuel Costamagna) Tj")
+	})
+
+	t.Run("should verify the certificate by its code", func(t *testing.T) {
+		code, b := do(t, http.MethodGet, srv.URL+"/certificates/"+strings.ToLower(cert.Code)+"/verify", nil)
+		require.Equal(t, http.StatusOK, code, b.Message)
+
+		var got map[string]any
+		require.NoError(t, json.Unmarshal(b.Data, &got))
+		assert.Equal(t, cert.Code, got["code"])
+		assert.Equal(t, "Nahuel Costamagna", got["student_name"])
+		assert.NotContains(t, got, "user_id")
+	})
+
+	t.Run("should not verify an unknown code", func(t *testing.T) {
+		code, b := do(t, http.MethodGet, srv.URL+"/v1/certificates/0000-0000-0000-0000/verify", nil)
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, enrollment.CodeCertificateNotFound, b.Code)
+	})
+}
+
+func TestEndToEndTracing(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	srv, api := newE2E(t)
+	api.AddUsers(domain.User{ID: user1, FirstName: "Nahuel"})
+	api.AddCourses(domain.Course{ID: course1, Name: "Go"})
+
+	b, err := json.Marshal(map[string]string{"user_id": user1, "course_id": course1})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/enrollments", bytes.NewReader(b))
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	t.Run("should send the trace to the user and course services", func(t *testing.T) {
+		for _, resource := range []string{fakeapi.Users, fakeapi.Courses} {
+			traceparent := api.Header(resource, "").Get("traceparent")
+			assert.True(t, strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-"), "%s: %q", resource, traceparent)
+		}
+	})
+
+	t.Run("should return the trace id", func(t *testing.T) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", resp.Header.Get("X-Trace-ID"))
+		assert.Empty(t, resp.Header.Get("traceparent"))
+	})
+}
@@ -0,0 +1,106 @@
+package enrollment
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ncostamagna/gocourse_enrollment/pkg/pdf"
+)
+
+// codeAlphabet is the Crockford base32 alphabet, without the letters that
+// can be taken for digits.
+const codeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var codeRe = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{4}(-[0-9A-HJKMNP-TV-Z]{4}){3}$`)
+
+type (
+	// Certificate is issued once per enrollment when it's completed. The
+	// student name and course title are copied at issue time, so the
+	// certificate doesn't change when the user or course does.
+	Certificate struct {
+		EnrollmentID string    `json:"enrollment_id" gorm:"primaryKey"`
+		Code         string    `json:"code"`
+		UserID       string    `json:"user_id"`
+		CourseID     string    `json:"course_id"`
+		StudentName  string    `json:"student_name"`
+		CourseTitle  string    `json:"course_title"`
+		IssuedAt     time.Time `json:"issued_at"`
+	}
+
+	// CertificateVerification is what anyone holding the code can read
+	// about a certificate.
+	CertificateVerification struct {
+		Code        string    `json:"code"`
+		StudentName string    `json:"student_name"`
+		CourseTitle string    `json:"course_title"`
+		IssuedAt    time.Time `json:"issued_at"`
+	}
+
+	// File is a download, the HTTP handler sends it as an attachment.
+	File struct {
+		Name        string
+		ContentType string
+		Content     []byte
+	}
+)
+
+func (Certificate) TableName() string { return "certificates" }
+
+// newCertificateCode returns a random code like 7KQ2-M9XD-4HRT-0CWV. Its 80
+// bits make guessing a valid code impractical, the database still rejects a
+// duplicated one.
+func newCertificateCode() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	var s strings.Builder
+	for i, c := range b {
+		if i > 0 && i%4 == 0 {
+			s.WriteByte('-')
+		}
+		s.WriteByte(codeAlphabet[c%32])
+	}
+	return s.String()
+}
+
+// NormalizeCertificateCode uppercases a code typed by someone reading it.
+func NormalizeCertificateCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Verification returns the public view of the certificate.
+func (c Certificate) Verification() CertificateVerification {
+	return CertificateVerification{
+		Code:        c.Code,
+		StudentName: c.StudentName,
+		CourseTitle: c.CourseTitle,
+		IssuedAt:    c.IssuedAt,
+	}
+}
+
+// PDF renders the certificate on an A4 landscape page.
+func (c Certificate) PDF() File {
+	p := pdf.NewPage(pdf.A4Height, pdf.A4Width)
+	p.Rect(24, 24, pdf.A4Height-48, pdf.A4Width-48, 3)
+	p.Rect(32, 32, pdf.A4Height-64, pdf.A4Width-64, 1)
+
+	p.Text(80, 470, pdf.HelveticaBold, 36, "Certificate of Completion")
+	p.Line(80, 455, 500, 455, 1)
+	p.Text(80, 400, pdf.Helvetica, 16, "This certifies that")
+	p.Text(80, 360, pdf.HelveticaBold, 28, c.StudentName)
+	p.Text(80, 310, pdf.Helvetica, 16, "has completed the course")
+	p.Text(80, 270, pdf.HelveticaBold, 24, c.CourseTitle)
+	p.Text(80, 220, pdf.Helvetica, 14, "Issued on "+c.IssuedAt.UTC().Format("January 2, 2006"))
+
+	p.Text(80, 100, pdf.Helvetica, 11, "Verification code: "+c.Code)
+	p.Text(80, 82, pdf.Helvetica, 11, fmt.Sprintf("Check it with GET /v1/certificates/%s/verify", c.Code))
+
+	return File{
+		Name:        fmt.Sprintf("certificate-%s.pdf", c.Code),
+		ContentType: "application/pdf",
+		Content:     p.Bytes(),
+	}
+}
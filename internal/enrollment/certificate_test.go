@@ -0,0 +1,41 @@
+package enrollment_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificate(t *testing.T) {
+	c := enrollment.Certificate{
+		EnrollmentID: testEnrollmentID,
+		Code:         "7KQ2-M9XD-4HRT-0CWV",
+		UserID:       testUserID,
+		CourseID:     testCourseID,
+		StudentName:  "Nahuel Costamagna",
+		CourseTitle:  "Go",
+		IssuedAt:     time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("should render the certificate as a PDF", func(t *testing.T) {
+		f := c.PDF()
+
+		assert.Equal(t, "certificate-7KQ2-M9XD-4HRT-0CWV.pdf", f.Name)
+		assert.Equal(t, "application/pdf", f.ContentType)
+		assert.Contains(t, string(f.Content), "(Nahuel Costamagna) Tj")
+		assert.Contains(t, string(f.Content), "(Go) Tj")
+		assert.Contains(t, string(f.Content), "(Issued on March 1, 2024) Tj")
+		assert.Contains(t, string(f.Content), "(Verification code: 7KQ2-M9XD-4HRT-0CWV) Tj")
+	})
+
+	t.Run("should only expose the public fields to verify it", func(t *testing.T) {
+		assert.Equal(t, enrollment.CertificateVerification{
+			Code:        c.Code,
+			StudentName: c.StudentName,
+			CourseTitle: c.CourseTitle,
+			IssuedAt:    c.IssuedAt,
+		}, c.Verification())
+	})
+}
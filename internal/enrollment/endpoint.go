@@ -2,13 +2,11 @@ package enrollment
 
 import (
 	"context"
-	"errors"
+	"time"
 
 	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
 	"github.com/ncostamagna/gocourse_meta/meta"
-
-	courseSdk "github.com/ncostamagna/go_course_sdk/course"
-	userSdk "github.com/ncostamagna/go_course_sdk/user"
 )
 
 // Endpoints struct
@@ -16,9 +14,15 @@ type (
 	Controller func(ctx context.Context, request interface{}) (interface{}, error)
 
 	Endpoints struct {
-		Create Controller
-		GetAll Controller
-		Update Controller
+		Create              Controller
+		Get                 Controller
+		GetAll              Controller
+		Update              Controller
+		RecordProgress      Controller
+		GetProgress         Controller
+		GetCertificate      Controller
+		DownloadCertificate Controller // returns a File
+		VerifyCertificate   Controller
 	}
 
 	CreateReq struct {
@@ -26,11 +30,20 @@ type (
 		CourseID string `json:"course_id"`
 	}
 
+	GetReq struct {
+		ID     string
+		Expand string
+	}
+
 	GetAllReq struct {
 		UserID   string
 		CourseID string
 		Limit    int
 		Page     int
+		// Expand is a comma separated list of the related records to embed.
+		Expand string
+		// Fields is a comma separated list of the enrollment fields to return.
+		Fields string
 	}
 
 	UpdateReq struct {
@@ -38,17 +51,61 @@ type (
 		Status *string `json:"status"`
 	}
 
+	RecordProgressReq struct {
+		ID       string
+		LessonID string `json:"lesson_id"`
+		// TotalLessons is the number of lessons of the course, which the
+		// course service doesn't know. It must match the first event.
+		TotalLessons int `json:"total_lessons"`
+	}
+
+	GetProgressReq struct {
+		ID string
+	}
+
+	GetCertificateReq struct {
+		ID string
+	}
+
+	VerifyCertificateReq struct {
+		Code string
+	}
+
+	// StreamReq is a request of the event stream, which the HTTP handler
+	// serves without an endpoint.
+	StreamReq struct {
+		UserID   string
+		CourseID string
+		// LastEventID resumes the stream after that event, an id that isn't
+		// one of ours gets a reset event.
+		LastEventID string
+	}
+
 	Config struct {
 		LimPageDef string
 	}
+
+	// CachedResponse is a read with the time its data last changed, the HTTP
+	// handler sends it as Last-Modified. It isn't used with expand, the users
+	// and courses can change without the enrollments.
+	CachedResponse struct {
+		response.Response
+		LastModified time.Time
+	}
 )
 
 // MakeEndpoints handler endpoints
 func MakeEndpoints(s Service, config Config) Endpoints {
 	return Endpoints{
-		Create: makeCreateEndpoint(s),
-		GetAll: makeGetAllEndpoint(s, config),
-		Update: makeUpdateEndpoint(s),
+		Create:              makeCreateEndpoint(s),
+		Get:                 makeGetEndpoint(s),
+		GetAll:              makeGetAllEndpoint(s, config),
+		Update:              makeUpdateEndpoint(s),
+		RecordProgress:      makeRecordProgressEndpoint(s),
+		GetProgress:         makeGetProgressEndpoint(s),
+		GetCertificate:      makeGetCertificateEndpoint(s),
+		DownloadCertificate: makeDownloadCertificateEndpoint(s),
+		VerifyCertificate:   makeVerifyCertificateEndpoint(s),
 	}
 }
 
@@ -56,27 +113,40 @@ func makeCreateEndpoint(s Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(CreateReq)
 
-		if req.UserID == "" {
-			return nil, response.BadRequest(ErrUserIDRequired.Error())
-		}
-
-		if req.CourseID == "" {
-			return nil, response.BadRequest(ErrCourseIDRequired.Error())
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
 		}
 
 		enroll, err := s.Create(ctx, req.UserID, req.CourseID)
 		if err != nil {
+			return nil, errorResponse(err)
+		}
 
-			if errors.As(err, &userSdk.ErrNotFound{}) ||
-				errors.As(err, &courseSdk.ErrNotFound{}) {
-				return nil, response.NotFound(err.Error())
-			}
+		return response.Created("success", enroll, nil), nil
+
+	}
+}
 
-			return nil, response.InternalServerError(err.Error())
+func makeGetEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetReq)
+
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
 		}
+		// checked by Validate
+		expand, _ := ParseExpand(req.Expand)
 
-		return response.Created("success", enroll, nil), nil
+		enroll, err := s.Get(ctx, req.ID)
+		if err != nil {
+			return nil, errorResponse(err)
+		}
+
+		if expand.Any() {
+			return response.OK("success", s.Expand(ctx, []domain.Enrollment{*enroll}, expand)[0], nil), nil
+		}
 
+		return cached(response.OK("success", enroll, nil), enroll.UpdatedAt), nil
 	}
 }
 
@@ -85,47 +155,181 @@ func makeGetAllEndpoint(s Service, config Config) Controller {
 
 		req := request.(GetAllReq)
 
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
+		}
+		// checked by Validate
+		expand, _ := ParseExpand(req.Expand)
+		fields, _ := ParseFields(req.Fields)
+
 		filters := Filters{
 			UserID:   req.UserID,
 			CourseID: req.CourseID,
+			Fields:   fields,
+		}
+		// the expansion needs the ids even when the client doesn't ask for them
+		if expand.User {
+			filters.Fields = filters.Fields.With("user_id")
+		}
+		if expand.Course {
+			filters.Fields = filters.Fields.With("course_id")
 		}
 
 		count, err := s.Count(ctx, filters)
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, errorResponse(err)
 		}
 
 		meta, err := meta.New(req.Page, req.Limit, count, config.LimPageDef)
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, errorResponse(err)
 		}
 
 		enrollments, err := s.GetAll(ctx, filters, meta.Offset(), meta.Limit())
 		if err != nil {
-			return nil, response.InternalServerError(err.Error())
+			return nil, errorResponse(err)
+		}
+
+		if expand.Any() && len(fields) == 0 {
+			return response.OK("success", s.Expand(ctx, enrollments, expand), meta), nil
 		}
 
-		return response.OK("success", enrollments, meta), nil
+		// read after the page, so a change between both queries makes the
+		// response look newer rather than older than it is
+		var lastUpdated *time.Time
+		if !expand.Any() {
+			if lastUpdated, err = s.LastUpdated(ctx, filters); err != nil {
+				return nil, errorResponse(err)
+			}
+		}
+
+		if len(fields) == 0 {
+			return cached(response.OK("success", enrollments, meta), lastUpdated), nil
+		}
+
+		var expanded []ExpandedEnrollment
+		if expand.Any() {
+			expanded = s.Expand(ctx, enrollments, expand)
+		} else {
+			expanded = make([]ExpandedEnrollment, len(enrollments))
+			for i, e := range enrollments {
+				expanded[i] = ExpandedEnrollment{Enrollment: e}
+			}
+		}
+
+		data := make([]map[string]interface{}, len(expanded))
+		for i, e := range expanded {
+			data[i] = fields.Select(e)
+		}
+		return cached(response.OK("success", data, meta), lastUpdated), nil
+	}
+}
+
+// cached adds the last modification time to the response when it's known.
+func cached(resp response.Response, lastModified *time.Time) response.Response {
+	if lastModified == nil {
+		return resp
 	}
+	return CachedResponse{Response: resp, LastModified: *lastModified}
 }
 
 func makeUpdateEndpoint(s Service) Controller {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(UpdateReq)
 
-		if req.Status != nil && *req.Status == "" {
-			return nil, response.BadRequest(ErrStatusRequired.Error())
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
 		}
 
 		if err := s.Update(ctx, req.ID, req.Status); err != nil {
+			return nil, errorResponse(err)
+		}
 
-			if errors.As(err, &ErrNotFound{}) {
-				return nil, response.NotFound(err.Error())
-			}
+		return response.OK("success", nil, nil), nil
+	}
+}
+
+func makeRecordProgressEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RecordProgressReq)
 
-			return nil, response.InternalServerError(err.Error())
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
 		}
 
-		return response.OK("success", nil, nil), nil
+		p, err := s.RecordProgress(ctx, req.ID, req.LessonID, req.TotalLessons)
+		if err != nil {
+			return nil, errorResponse(err)
+		}
+
+		return response.OK("success", p, nil), nil
+	}
+}
+
+func makeGetProgressEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetProgressReq)
+
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
+		}
+
+		p, err := s.GetProgress(ctx, req.ID)
+		if err != nil {
+			return nil, errorResponse(err)
+		}
+
+		return response.OK("success", p, nil), nil
+	}
+}
+
+func makeGetCertificateEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetCertificateReq)
+
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
+		}
+
+		c, err := s.GetCertificate(ctx, req.ID)
+		if err != nil {
+			return nil, errorResponse(err)
+		}
+
+		return response.OK("success", c, nil), nil
+	}
+}
+
+func makeDownloadCertificateEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetCertificateReq)
+
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
+		}
+
+		c, err := s.GetCertificate(ctx, req.ID)
+		if err != nil {
+			return nil, errorResponse(err)
+		}
+
+		return c.PDF(), nil
+	}
+}
+
+func makeVerifyCertificateEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(VerifyCertificateReq)
+
+		if errs := Validate(req); errs != nil {
+			return nil, InvalidFields(errs)
+		}
+
+		c, err := s.VerifyCertificate(ctx, req.Code)
+		if err != nil {
+			return nil, errorResponse(err)
+		}
+
+		return response.OK("success", c.Verification(), nil), nil
 	}
 }
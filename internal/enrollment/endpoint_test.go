@@ -4,9 +4,10 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"testing"
+	"time"
 
 	mockCourseSdk "github.com/ncostamagna/go_course_sdk/course/mock"
 	mockUserSdk "github.com/ncostamagna/go_course_sdk/user/mock"
@@ -18,32 +19,38 @@ import (
 	"github.com/ncostamagna/gocourse_domain/domain"
 	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateEndpoint(t *testing.T) {
 
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
 
-	t.Run("should return bad request when user id is empty", func(t *testing.T) {
+	t.Run("should return bad request listing every missing id", func(t *testing.T) {
 		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
 		_, err := endpoint.Create(context.Background(), enrollment.CreateReq{})
 		assert.Error(t, err)
 
-		resp := err.(response.Response)
+		resp := err.(*enrollment.ErrorResponse)
 
-		assert.EqualError(t, enrollment.ErrUserIDRequired, resp.Error())
 		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+		assert.Equal(t, enrollment.CodeInvalidRequest, resp.Code)
+		assert.Equal(t, enrollment.FieldErrors{
+			{Field: "user_id", Message: "is required"},
+			{Field: "course_id", Message: "is required"},
+		}, resp.Fields)
 	})
 
-	t.Run("should return bad request when course id is empty", func(t *testing.T) {
+	t.Run("should return bad request when an id isn't a UUID", func(t *testing.T) {
 		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
-		_, err := endpoint.Create(context.Background(), enrollment.CreateReq{UserID: "123"})
+		_, err := endpoint.Create(context.Background(), enrollment.CreateReq{UserID: "123", CourseID: testCourseID})
 		assert.Error(t, err)
 
-		resp := err.(response.Response)
+		resp := err.(*enrollment.ErrorResponse)
 
-		assert.EqualError(t, enrollment.ErrCourseIDRequired, resp.Error())
 		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+		assert.Equal(t, enrollment.FieldErrors{{Field: "user_id", Message: "must be a UUID"}}, resp.Fields)
+		assert.Equal(t, "user_id: must be a UUID", resp.Error())
 	})
 
 	obj := []struct {
@@ -53,6 +60,7 @@ func TestCreateEndpoint(t *testing.T) {
 		courseSdkMock  courseSdk.Transport
 		wantErr        error
 		wantCode       int
+		wantErrCode    string
 		wantResponse   *domain.Enrollment
 	}{
 		{
@@ -62,8 +70,9 @@ func TestCreateEndpoint(t *testing.T) {
 					return nil, errors.New("unexpected error")
 				},
 			},
-			wantErr:  errors.New("unexpected error"),
-			wantCode: http.StatusInternalServerError,
+			wantErr:     errors.New("the user service is unavailable, retry later"),
+			wantCode:    http.StatusServiceUnavailable,
+			wantErrCode: enrollment.CodeDependencyUnavailable,
 		},
 		{
 			tag: "should return an error if user does not exist",
@@ -72,8 +81,9 @@ func TestCreateEndpoint(t *testing.T) {
 					return nil, userSdk.ErrNotFound{Message: "user not found"}
 				},
 			},
-			wantErr:  userSdk.ErrNotFound{Message: "user not found"},
-			wantCode: http.StatusNotFound,
+			wantErr:     userSdk.ErrNotFound{Message: "user not found"},
+			wantCode:    http.StatusNotFound,
+			wantErrCode: enrollment.CodeUserNotFound,
 		},
 		{
 			tag: "should return an error if course skd returns an unexpected error",
@@ -87,8 +97,9 @@ func TestCreateEndpoint(t *testing.T) {
 					return nil, errors.New("unexpected error")
 				},
 			},
-			wantErr:  errors.New("unexpected error"),
-			wantCode: http.StatusInternalServerError,
+			wantErr:     errors.New("the course service is unavailable, retry later"),
+			wantCode:    http.StatusServiceUnavailable,
+			wantErrCode: enrollment.CodeDependencyUnavailable,
 		},
 		{
 			tag: "should return an error if course does not exist",
@@ -102,8 +113,9 @@ func TestCreateEndpoint(t *testing.T) {
 					return nil, courseSdk.ErrNotFound{Message: "course not found"}
 				},
 			},
-			wantErr:  courseSdk.ErrNotFound{Message: "course not found"},
-			wantCode: http.StatusNotFound,
+			wantErr:     courseSdk.ErrNotFound{Message: "course not found"},
+			wantCode:    http.StatusNotFound,
+			wantErrCode: enrollment.CodeCourseNotFound,
 		},
 		{
 			tag: "should return an error if repository returns an unexpected error",
@@ -118,12 +130,37 @@ func TestCreateEndpoint(t *testing.T) {
 				},
 			},
 			repositoryMock: &mockRepository{
+				CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+					return 0, nil
+				},
 				CreateMock: func(ctx context.Context, enrollment *domain.Enrollment) error {
 					return errors.New("unexpected error")
 				},
 			},
-			wantErr:  errors.New("unexpected error"),
-			wantCode: http.StatusInternalServerError,
+			wantErr:     errors.New("internal error"),
+			wantCode:    http.StatusInternalServerError,
+			wantErrCode: enrollment.CodeInternal,
+		},
+		{
+			tag: "should return an error if the user is already enrolled",
+			userSdkMock: &mockUserSdk.UserSdkMock{
+				GetMock: func(id string) (*domain.User, error) {
+					return nil, nil
+				},
+			},
+			courseSdkMock: &mockCourseSdk.CourseSdkMock{
+				GetMock: func(id string) (*domain.Course, error) {
+					return nil, nil
+				},
+			},
+			repositoryMock: &mockRepository{
+				CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+					return 1, nil
+				},
+			},
+			wantErr:     enrollment.ErrAlreadyEnrolled,
+			wantCode:    http.StatusConflict,
+			wantErrCode: enrollment.CodeAlreadyEnrolled,
 		},
 		{
 			tag: "should return the enrollment",
@@ -138,6 +175,9 @@ func TestCreateEndpoint(t *testing.T) {
 				},
 			},
 			repositoryMock: &mockRepository{
+				CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+					return 0, nil
+				},
 				CreateMock: func(ctx context.Context, enrollment *domain.Enrollment) error {
 					enrollment.ID = "10010"
 					return nil
@@ -146,8 +186,8 @@ func TestCreateEndpoint(t *testing.T) {
 			wantCode: http.StatusCreated,
 			wantResponse: &domain.Enrollment{
 				ID:       "10010",
-				UserID:   "1",
-				CourseID: "4",
+				UserID:   testUserID,
+				CourseID: testCourseID,
 				Status:   "P",
 			},
 		},
@@ -157,15 +197,16 @@ func TestCreateEndpoint(t *testing.T) {
 		t.Run(obj.tag, func(t *testing.T) {
 			service := enrollment.NewService(l, obj.userSdkMock, obj.courseSdkMock, obj.repositoryMock)
 			endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
-			resp, err := endpoint.Create(context.Background(), enrollment.CreateReq{UserID: "1", CourseID: "4"})
+			resp, err := endpoint.Create(context.Background(), enrollment.CreateReq{UserID: testUserID, CourseID: testCourseID})
 
 			if obj.wantErr != nil {
 				assert.NotNil(t, err)
 				assert.Nil(t, resp)
 
-				r := err.(response.Response)
+				r := err.(*enrollment.ErrorResponse)
 				assert.EqualError(t, obj.wantErr, r.Error())
 				assert.Equal(t, obj.wantCode, r.StatusCode())
+				assert.Equal(t, obj.wantErrCode, r.Code)
 			} else {
 				assert.NotNil(t, resp)
 				assert.Nil(t, err)
@@ -185,10 +226,10 @@ func TestCreateEndpoint(t *testing.T) {
 }
 
 func TestGetAllEndpoint(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	t.Run("should return an error if Count returns an unexpected error", func(t *testing.T) {
-		wantErr := errors.New("unexpected error")
+		wantErr := errors.New("internal error")
 		service := enrollment.NewService(l, nil, nil, &mockRepository{
 			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
 				return 0, errors.New("unexpected error")
@@ -204,7 +245,7 @@ func TestGetAllEndpoint(t *testing.T) {
 	})
 
 	t.Run("should return an error if meta returns a parsing error", func(t *testing.T) {
-		wantErr := errors.New("strconv.Atoi: parsing \"invalid number\": invalid syntax")
+		wantErr := errors.New("internal error")
 		service := enrollment.NewService(l, nil, nil, &mockRepository{
 			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
 				return 3, nil
@@ -220,7 +261,7 @@ func TestGetAllEndpoint(t *testing.T) {
 	})
 
 	t.Run("should return an error if GetAll repository returns an unexpected error", func(t *testing.T) {
-		wantErr := errors.New("unexpected error")
+		wantErr := errors.New("internal error")
 		service := enrollment.NewService(l, nil, nil, &mockRepository{
 			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
 				return 3, nil
@@ -239,23 +280,11 @@ func TestGetAllEndpoint(t *testing.T) {
 	})
 
 	t.Run("should return the enrollments", func(t *testing.T) {
-		wantEnrollments := []domain.Enrollment{
-			{ID: "1", UserID: "11", CourseID: "111", Status: "P"},
-			{ID: "2", UserID: "22", CourseID: "222", Status: "P"},
-			{ID: "3", UserID: "33", CourseID: "333", Status: "P"},
-		}
-		service := enrollment.NewService(l, nil, nil, &mockRepository{
-			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
-				return 3, nil
-			},
-			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
-				return []domain.Enrollment{
-					{ID: "1", UserID: "11", CourseID: "111", Status: "P"},
-					{ID: "2", UserID: "22", CourseID: "222", Status: "P"},
-					{ID: "3", UserID: "33", CourseID: "333", Status: "P"},
-				}, nil
-			},
-		})
+		service := enrollment.NewService(l, nil, nil, newMemoryRepo(t,
+			domain.Enrollment{ID: "1", UserID: "11", CourseID: "111", Status: "P"},
+			domain.Enrollment{ID: "2", UserID: "22", CourseID: "222", Status: "P"},
+			domain.Enrollment{ID: "3", UserID: "33", CourseID: "333", Status: "P"},
+		))
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimPageDef: "10"})
 		resp, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{})
 		assert.Nil(t, err)
@@ -265,43 +294,89 @@ func TestGetAllEndpoint(t *testing.T) {
 		assert.Empty(t, r.Error())
 
 		enrollments := r.GetData().([]domain.Enrollment)
-		assert.Equal(t, wantEnrollments, enrollments)
+		assert.Len(t, enrollments, 3)
+		for i, id := range []string{"3", "2", "1"} {
+			assert.Equal(t, id, enrollments[i].ID)
+		}
+
+	})
+
+	t.Run("should return an error if LastUpdated repository returns an unexpected error", func(t *testing.T) {
+		service := enrollment.NewService(l, nil, nil, &mockRepository{
+			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+				return 0, nil
+			},
+			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
+				return nil, nil
+			},
+			LastUpdatedMock: func(ctx context.Context, filters enrollment.Filters) (*time.Time, error) {
+				return nil, errors.New("unexpected error")
+			},
+		})
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimPageDef: "10"})
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{})
+
+		resp := err.(response.Response)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode())
+	})
 
+	t.Run("should return the last update of the filtered enrollments", func(t *testing.T) {
+		service := enrollment.NewService(l, nil, nil, newMemoryRepo(t,
+			domain.Enrollment{ID: "1", UserID: testUserID, CourseID: "111", Status: "P"},
+			domain.Enrollment{ID: "2", UserID: testUserID, CourseID: "222", Status: "P"},
+			domain.Enrollment{ID: "3", UserID: "33", CourseID: "333", Status: "P"},
+		))
+		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{LimPageDef: "1"})
+		resp, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{UserID: testUserID, Page: 2})
+		require.NoError(t, err)
+
+		r := resp.(enrollment.CachedResponse)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC), r.LastModified)
+		assert.Equal(t, "1", r.GetData().([]domain.Enrollment)[0].ID)
 	})
 }
 
 func TestUpdateEndpoint(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	t.Run("should return an error if status is empty", func(t *testing.T) {
 		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
 		status := ""
-		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{Status: &status})
+		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: testEnrollmentID, Status: &status})
 		assert.Error(t, err)
 
-		resp := err.(response.Response)
-		assert.EqualError(t, enrollment.ErrStatusRequired, resp.Error())
+		resp := err.(*enrollment.ErrorResponse)
+		assert.Equal(t, enrollment.FieldErrors{{Field: "status", Message: "can't be empty"}}, resp.Fields)
 		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
 	})
 
+	t.Run("should return an error if status isn't allowed", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{})
+		status := "X"
+		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
+		assert.Error(t, err)
+
+		resp := err.(*enrollment.ErrorResponse)
+		assert.Equal(t, enrollment.FieldErrors{
+			{Field: "id", Message: "must be a UUID"},
+			{Field: "status", Message: "must be P, A, S, I or C"},
+		}, resp.Fields)
+	})
+
 	t.Run("should return an error if repository retunrs a not found error", func(t *testing.T) {
-		service := enrollment.NewService(l, nil, nil, &mockRepository{
-			UpdateMock: func(ctx context.Context, id string, status *string) error {
-				return enrollment.ErrNotFound{EnrollmentsID: id}
-			},
-		})
+		service := enrollment.NewService(l, nil, nil, newMemoryRepo(t))
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
 		status := "A"
-		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
+		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: testMissingID, Status: &status})
 		assert.Error(t, err)
 
 		resp := err.(response.Response)
-		assert.EqualError(t, enrollment.ErrNotFound{EnrollmentsID: "20"}, resp.Error())
+		assert.EqualError(t, enrollment.ErrNotFound{EnrollmentsID: testMissingID}, resp.Error())
 		assert.Equal(t, http.StatusNotFound, resp.StatusCode())
 	})
 
 	t.Run("should return an error if repository retunrs a unexpected error", func(t *testing.T) {
-		wantErr := errors.New("unexpected error")
+		wantErr := errors.New("internal error")
 		service := enrollment.NewService(l, nil, nil, &mockRepository{
 			UpdateMock: func(ctx context.Context, id string, status *string) error {
 				return errors.New("unexpected error")
@@ -309,7 +384,7 @@ func TestUpdateEndpoint(t *testing.T) {
 		})
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
 		status := "A"
-		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
+		_, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: testEnrollmentID, Status: &status})
 		assert.Error(t, err)
 
 		resp := err.(response.Response)
@@ -318,17 +393,12 @@ func TestUpdateEndpoint(t *testing.T) {
 	})
 
 	t.Run("should return success", func(t *testing.T) {
-		service := enrollment.NewService(l, nil, nil, &mockRepository{
-			UpdateMock: func(ctx context.Context, id string, status *string) error {
-				assert.Equal(t, "20", id)
-				assert.NotNil(t, status)
-				assert.Equal(t, "A", *status)
-				return nil
-			},
-		})
+		service := enrollment.NewService(l, nil, nil, newMemoryRepo(t,
+			domain.Enrollment{ID: testEnrollmentID, UserID: "11", CourseID: "111", Status: "P"},
+		))
 		endpoint := enrollment.MakeEndpoints(service, enrollment.Config{})
 		status := "A"
-		resp, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: "20", Status: &status})
+		resp, err := endpoint.Update(context.Background(), enrollment.UpdateReq{ID: testEnrollmentID, Status: &status})
 		assert.Nil(t, err)
 
 		r := resp.(response.Response)
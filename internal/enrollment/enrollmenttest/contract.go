@@ -0,0 +1,475 @@
+// Package enrollmenttest provides the contract every enrollment.Repository
+// implementation must satisfy.
+package enrollmenttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RepositoryContract runs the contract suite. newRepo must return an empty
+// repository on every call so the subtests don't share data.
+func RepositoryContract(t *testing.T, newRepo func(t *testing.T) enrollment.Repository) {
+
+	t.Run("Create", func(t *testing.T) { testCreate(t, newRepo(t)) })
+	t.Run("Get", func(t *testing.T) { testGet(t, newRepo(t)) })
+	t.Run("GetAll", func(t *testing.T) { testGetAll(t, newRepo(t)) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newRepo(t)) })
+	t.Run("Count", func(t *testing.T) { testCount(t, newRepo(t)) })
+	t.Run("LastUpdated", func(t *testing.T) { testLastUpdated(t, newRepo(t)) })
+	t.Run("Progress", func(t *testing.T) { testProgress(t, newRepo(t)) })
+	t.Run("Certificates", func(t *testing.T) { testCertificates(t, newRepo(t)) })
+}
+
+// seed stores the enrollments with a creation time one minute apart, so the
+// last one is the newest.
+func seed(t *testing.T, repo enrollment.Repository, enrolls ...domain.Enrollment) []domain.Enrollment {
+	t.Helper()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range enrolls {
+		createdAt := base.Add(time.Duration(i) * time.Minute)
+		enrolls[i].CreatedAt = &createdAt
+		enrolls[i].UpdatedAt = &createdAt
+		require.NoError(t, repo.Create(context.Background(), &enrolls[i]))
+	}
+	return enrolls
+}
+
+func ids(enrolls []domain.Enrollment) []string {
+	res := make([]string, 0, len(enrolls))
+	for _, e := range enrolls {
+		res = append(res, e.ID)
+	}
+	return res
+}
+
+func testCreate(t *testing.T, repo enrollment.Repository) {
+	ctx := context.Background()
+
+	t.Run("should assign an id when it's empty", func(t *testing.T) {
+		e := &domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"}
+		require.NoError(t, repo.Create(ctx, e))
+		assert.NotEmpty(t, e.ID)
+	})
+
+	t.Run("should keep the given id and store every field", func(t *testing.T) {
+		e := &domain.Enrollment{ID: "5f0a6a84-0c9f-4f55-9c4b-4bd2b4ab2b10", UserID: "u-2", CourseID: "c-2", Status: "A"}
+		require.NoError(t, repo.Create(ctx, e))
+		assert.Equal(t, "5f0a6a84-0c9f-4f55-9c4b-4bd2b4ab2b10", e.ID)
+
+		got, err := repo.GetAll(ctx, enrollment.Filters{UserID: "u-2"}, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, e.ID, got[0].ID)
+		assert.Equal(t, "u-2", got[0].UserID)
+		assert.Equal(t, "c-2", got[0].CourseID)
+		assert.Equal(t, "A", got[0].Status)
+		assert.NotNil(t, got[0].CreatedAt)
+	})
+
+	t.Run("should reject a duplicated id", func(t *testing.T) {
+		e := &domain.Enrollment{ID: "5f0a6a84-0c9f-4f55-9c4b-4bd2b4ab2b10", UserID: "u-3", CourseID: "c-3", Status: "P"}
+		assert.Error(t, repo.Create(ctx, e))
+	})
+
+	t.Run("should return ErrAlreadyEnrolled for the same user and course", func(t *testing.T) {
+		e := &domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"}
+		assert.ErrorIs(t, repo.Create(ctx, e), enrollment.ErrAlreadyEnrolled)
+
+		count, err := repo.Count(ctx, enrollment.Filters{UserID: "u-1", CourseID: "c-1"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func testGet(t *testing.T, repo enrollment.Repository) {
+	ctx := context.Background()
+
+	enrolls := seed(t, repo,
+		domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"},
+		domain.Enrollment{UserID: "u-2", CourseID: "c-2", Status: "A"},
+	)
+
+	t.Run("should return the enrollment", func(t *testing.T) {
+		got, err := repo.Get(ctx, enrolls[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, enrolls[1].ID, got.ID)
+		assert.Equal(t, "u-2", got.UserID)
+		assert.Equal(t, "c-2", got.CourseID)
+		assert.Equal(t, "A", got.Status)
+		require.NotNil(t, got.CreatedAt)
+		assert.True(t, enrolls[1].CreatedAt.Equal(*got.CreatedAt))
+	})
+
+	t.Run("should return ErrNotFound when the enrollment doesn't exist", func(t *testing.T) {
+		id := "00000000-0000-0000-0000-000000000000"
+		_, err := repo.Get(ctx, id)
+
+		var notFound enrollment.ErrNotFound
+		require.True(t, errors.As(err, &notFound), "unexpected error: %v", err)
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentsID: id}, notFound)
+	})
+}
+
+func testGetAll(t *testing.T, repo enrollment.Repository) {
+	ctx := context.Background()
+
+	enrolls := seed(t, repo,
+		domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"},
+		domain.Enrollment{UserID: "u-1", CourseID: "c-2", Status: "A"},
+		domain.Enrollment{UserID: "u-2", CourseID: "c-1", Status: "P"},
+		domain.Enrollment{UserID: "u-2", CourseID: "c-2", Status: "S"},
+		domain.Enrollment{UserID: "u-3", CourseID: "c-1", Status: "F"},
+	)
+
+	obj := []struct {
+		name    string
+		filters enrollment.Filters
+		offset  int
+		limit   int
+		want    []domain.Enrollment
+	}{
+		{
+			name:  "should return every enrollment, newest first",
+			limit: 10,
+			want:  []domain.Enrollment{enrolls[4], enrolls[3], enrolls[2], enrolls[1], enrolls[0]},
+		},
+		{
+			name:    "should filter by user",
+			filters: enrollment.Filters{UserID: "u-1"},
+			limit:   10,
+			want:    []domain.Enrollment{enrolls[1], enrolls[0]},
+		},
+		{
+			name:    "should filter by course",
+			filters: enrollment.Filters{CourseID: "c-1"},
+			limit:   10,
+			want:    []domain.Enrollment{enrolls[4], enrolls[2], enrolls[0]},
+		},
+		{
+			name:    "should filter by user and course",
+			filters: enrollment.Filters{UserID: "u-2", CourseID: "c-2"},
+			limit:   10,
+			want:    []domain.Enrollment{enrolls[3]},
+		},
+		{
+			name:  "should return the first page",
+			limit: 2,
+			want:  []domain.Enrollment{enrolls[4], enrolls[3]},
+		},
+		{
+			name:   "should return the last page",
+			offset: 4,
+			limit:  2,
+			want:   []domain.Enrollment{enrolls[0]},
+		},
+		{
+			name:    "should paginate the filtered enrollments",
+			filters: enrollment.Filters{CourseID: "c-1"},
+			offset:  1,
+			limit:   1,
+			want:    []domain.Enrollment{enrolls[2]},
+		},
+		{
+			name:   "should return an empty page past the end",
+			offset: 10,
+			limit:  2,
+			want:   []domain.Enrollment{},
+		},
+		{
+			name:    "should return nothing when no enrollment matches",
+			filters: enrollment.Filters{UserID: "u-9"},
+			limit:   10,
+			want:    []domain.Enrollment{},
+		},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(ctx, tt.filters, tt.offset, tt.limit)
+			require.NoError(t, err)
+			assert.Equal(t, ids(tt.want), ids(got))
+		})
+	}
+
+	t.Run("should only read the selected fields", func(t *testing.T) {
+		got, err := repo.GetAll(ctx, enrollment.Filters{UserID: "u-1", Fields: enrollment.Fields{"id", "status"}}, 0, 10)
+		require.NoError(t, err)
+
+		assert.Equal(t, []domain.Enrollment{
+			{ID: enrolls[1].ID, Status: "A"},
+			{ID: enrolls[0].ID, Status: "P"},
+		}, got)
+	})
+
+	t.Run("should order enrollments created at the same time by id", func(t *testing.T) {
+		createdAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, id := range []string{"00000000-0000-0000-0000-00000000000a", "00000000-0000-0000-0000-00000000000b"} {
+			require.NoError(t, repo.Create(ctx, &domain.Enrollment{
+				ID: id, UserID: "u-4", CourseID: fmt.Sprintf("c-4%d", i), Status: "P", CreatedAt: &createdAt, UpdatedAt: &createdAt,
+			}))
+		}
+
+		got, err := repo.GetAll(ctx, enrollment.Filters{UserID: "u-4"}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"00000000-0000-0000-0000-00000000000b", "00000000-0000-0000-0000-00000000000a"}, ids(got))
+	})
+}
+
+func testUpdate(t *testing.T, repo enrollment.Repository) {
+	ctx := context.Background()
+
+	enrolls := seed(t, repo,
+		domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"},
+		domain.Enrollment{UserID: "u-2", CourseID: "c-1", Status: "P"},
+	)
+
+	t.Run("should update the status", func(t *testing.T) {
+		status := "A"
+		require.NoError(t, repo.Update(ctx, enrolls[0].ID, &status))
+
+		got, err := repo.GetAll(ctx, enrollment.Filters{UserID: "u-1"}, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "A", got[0].Status)
+		assert.Equal(t, "c-1", got[0].CourseID)
+	})
+
+	t.Run("should not update the other enrollments", func(t *testing.T) {
+		got, err := repo.GetAll(ctx, enrollment.Filters{UserID: "u-2"}, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "P", got[0].Status)
+	})
+
+	t.Run("should only move the update time without a status", func(t *testing.T) {
+		require.NoError(t, repo.Update(ctx, enrolls[1].ID, nil))
+
+		got, err := repo.GetAll(ctx, enrollment.Filters{UserID: "u-2"}, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "P", got[0].Status)
+		require.NotNil(t, got[0].UpdatedAt)
+		assert.True(t, got[0].UpdatedAt.After(*enrolls[1].UpdatedAt), "got %v", got[0].UpdatedAt)
+	})
+
+	t.Run("should return ErrNotFound when the enrollment doesn't exist", func(t *testing.T) {
+		status := "A"
+		id := "00000000-0000-0000-0000-000000000000"
+		err := repo.Update(ctx, id, &status)
+
+		var notFound enrollment.ErrNotFound
+		require.True(t, errors.As(err, &notFound), "unexpected error: %v", err)
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentsID: id}, notFound)
+	})
+}
+
+func testCount(t *testing.T, repo enrollment.Repository) {
+	ctx := context.Background()
+
+	t.Run("should return zero when it's empty", func(t *testing.T) {
+		count, err := repo.Count(ctx, enrollment.Filters{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	seed(t, repo,
+		domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"},
+		domain.Enrollment{UserID: "u-1", CourseID: "c-2", Status: "P"},
+		domain.Enrollment{UserID: "u-2", CourseID: "c-1", Status: "P"},
+	)
+
+	obj := []struct {
+		name    string
+		filters enrollment.Filters
+		want    int
+	}{
+		{name: "should count every enrollment", want: 3},
+		{name: "should count by user", filters: enrollment.Filters{UserID: "u-1"}, want: 2},
+		{name: "should count by course", filters: enrollment.Filters{CourseID: "c-1"}, want: 2},
+		{name: "should count by user and course", filters: enrollment.Filters{UserID: "u-2", CourseID: "c-1"}, want: 1},
+		{name: "should count zero when no enrollment matches", filters: enrollment.Filters{CourseID: "c-9"}, want: 0},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := repo.Count(ctx, tt.filters)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, count)
+		})
+	}
+}
+
+func testLastUpdated(t *testing.T, repo enrollment.Repository) {
+	ctx := context.Background()
+
+	t.Run("should return nil when it's empty", func(t *testing.T) {
+		last, err := repo.LastUpdated(ctx, enrollment.Filters{})
+		require.NoError(t, err)
+		assert.Nil(t, last)
+	})
+
+	enrolls := seed(t, repo,
+		domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"},
+		domain.Enrollment{UserID: "u-1", CourseID: "c-2", Status: "P"},
+		domain.Enrollment{UserID: "u-2", CourseID: "c-1", Status: "P"},
+	)
+
+	obj := []struct {
+		name    string
+		filters enrollment.Filters
+		want    *time.Time
+	}{
+		{name: "should return the last update of every enrollment", want: enrolls[2].UpdatedAt},
+		{name: "should return the last update of a user", filters: enrollment.Filters{UserID: "u-1"}, want: enrolls[1].UpdatedAt},
+		{name: "should return the last update of a course", filters: enrollment.Filters{CourseID: "c-1"}, want: enrolls[2].UpdatedAt},
+		{name: "should return nil when no enrollment matches", filters: enrollment.Filters{CourseID: "c-9"}},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.name, func(t *testing.T) {
+			last, err := repo.LastUpdated(ctx, tt.filters)
+			require.NoError(t, err)
+			if tt.want == nil {
+				assert.Nil(t, last)
+				return
+			}
+			require.NotNil(t, last)
+			assert.True(t, tt.want.Equal(*last), "want %v, got %v", tt.want, last)
+		})
+	}
+
+	t.Run("should move forward when an enrollment is updated", func(t *testing.T) {
+		status := "A"
+		require.NoError(t, repo.Update(ctx, enrolls[0].ID, &status))
+
+		last, err := repo.LastUpdated(ctx, enrollment.Filters{UserID: "u-1"})
+		require.NoError(t, err)
+		require.NotNil(t, last)
+		assert.True(t, last.After(*enrolls[2].UpdatedAt), "got %v", last)
+	})
+}
+
+func testProgress(t *testing.T, repo enrollment.Repository) {
+	ctx := context.Background()
+
+	enrolls := seed(t, repo,
+		domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"},
+		domain.Enrollment{UserID: "u-2", CourseID: "c-1", Status: "P"},
+	)
+	id := enrolls[0].ID
+	base := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should return an empty progress without events", func(t *testing.T) {
+		got, err := repo.GetProgress(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, &enrollment.Progress{EnrollmentID: id, CompletedLessons: []string{}}, got)
+	})
+
+	t.Run("should aggregate the completed lessons", func(t *testing.T) {
+		require.NoError(t, repo.RecordProgress(ctx, id, "l-2", 4, base))
+		require.NoError(t, repo.RecordProgress(ctx, id, "l-1", 4, base.Add(time.Minute)))
+
+		got, err := repo.GetProgress(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, id, got.EnrollmentID)
+		assert.Equal(t, 50, got.Percent)
+		assert.Equal(t, 4, got.TotalLessons)
+		assert.Equal(t, []string{"l-2", "l-1"}, got.CompletedLessons)
+		require.NotNil(t, got.LastActivityAt)
+		assert.True(t, base.Add(time.Minute).Equal(*got.LastActivityAt), "got %v", got.LastActivityAt)
+	})
+
+	t.Run("should count a lesson completed again once", func(t *testing.T) {
+		require.NoError(t, repo.RecordProgress(ctx, id, "l-2", 4, base.Add(2*time.Minute)))
+
+		got, err := repo.GetProgress(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, 50, got.Percent)
+		assert.Equal(t, []string{"l-2", "l-1"}, got.CompletedLessons)
+		require.NotNil(t, got.LastActivityAt)
+		assert.True(t, base.Add(2*time.Minute).Equal(*got.LastActivityAt), "got %v", got.LastActivityAt)
+	})
+
+	t.Run("should keep the total of lessons of the first event", func(t *testing.T) {
+		err := repo.RecordProgress(ctx, id, "l-3", 3, base.Add(3*time.Minute))
+		assert.Equal(t, enrollment.ErrTotalLessonsChanged{EnrollmentID: id, TotalLessons: 4}, err)
+
+		got, err := repo.GetProgress(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, 50, got.Percent)
+		assert.Equal(t, 4, got.TotalLessons)
+		assert.Equal(t, []string{"l-2", "l-1"}, got.CompletedLessons)
+		require.NotNil(t, got.LastActivityAt)
+		assert.True(t, base.Add(2*time.Minute).Equal(*got.LastActivityAt), "got %v", got.LastActivityAt)
+	})
+
+	t.Run("should not mix the progress of the enrollments", func(t *testing.T) {
+		got, err := repo.GetProgress(ctx, enrolls[1].ID)
+		require.NoError(t, err)
+		assert.Zero(t, got.Percent)
+		assert.Empty(t, got.CompletedLessons)
+	})
+}
+
+func testCertificates(t *testing.T, repo enrollment.Repository) {
+	ctx := context.Background()
+
+	enrolls := seed(t, repo,
+		domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "C"},
+		domain.Enrollment{UserID: "u-2", CourseID: "c-1", Status: "C"},
+	)
+	issuedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := enrollment.Certificate{
+		EnrollmentID: enrolls[0].ID,
+		Code:         "7KQ2-M9XD-4HRT-0CWV",
+		UserID:       "u-1",
+		CourseID:     "c-1",
+		StudentName:  "Nahuel Costamagna",
+		CourseTitle:  "Go",
+		IssuedAt:     issuedAt,
+	}
+
+	t.Run("should store the certificate", func(t *testing.T) {
+		require.NoError(t, repo.CreateCertificate(ctx, &c))
+
+		got, err := repo.GetCertificate(ctx, enrolls[0].ID)
+		require.NoError(t, err)
+		assert.True(t, issuedAt.Equal(got.IssuedAt), "got %v", got.IssuedAt)
+		got.IssuedAt = issuedAt
+		assert.Equal(t, c, *got)
+
+		got, err = repo.GetCertificateByCode(ctx, c.Code)
+		require.NoError(t, err)
+		assert.Equal(t, c.EnrollmentID, got.EnrollmentID)
+	})
+
+	t.Run("should reject a second certificate of the enrollment", func(t *testing.T) {
+		other := c
+		other.Code = "8KQ2-M9XD-4HRT-0CWV"
+		assert.Error(t, repo.CreateCertificate(ctx, &other))
+	})
+
+	t.Run("should reject a duplicated code", func(t *testing.T) {
+		other := c
+		other.EnrollmentID = enrolls[1].ID
+		assert.Error(t, repo.CreateCertificate(ctx, &other))
+	})
+
+	t.Run("should return ErrCertificateNotFound when it doesn't exist", func(t *testing.T) {
+		_, err := repo.GetCertificate(ctx, enrolls[1].ID)
+		assert.Equal(t, enrollment.ErrCertificateNotFound{EnrollmentID: enrolls[1].ID}, err)
+
+		_, err = repo.GetCertificateByCode(ctx, "0000-0000-0000-0000")
+		assert.Equal(t, enrollment.ErrCertificateNotFound{Code: "0000-0000-0000-0000"}, err)
+	})
+}
@@ -1,13 +1,16 @@
 package enrollment
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+
+	courseSdk "github.com/ncostamagna/go_course_sdk/course"
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
 )
 
-var ErrUserIDRequired = errors.New("user id is required")
-var ErrCourseIDRequired = errors.New("course id is required")
-var ErrStatusRequired = errors.New("status is required")
+var ErrAlreadyEnrolled = errors.New("the user is already enrolled in the course")
 
 type ErrNotFound struct {
 	EnrollmentsID string
@@ -16,3 +19,140 @@ type ErrNotFound struct {
 func (e ErrNotFound) Error() string {
 	return fmt.Sprintf("enrollment '%s' doesn't exist", e.EnrollmentsID)
 }
+
+// ErrCertificateNotFound is a missing certificate, looked up by its code or
+// by its enrollment.
+type ErrCertificateNotFound struct {
+	Code         string
+	EnrollmentID string
+}
+
+func (e ErrCertificateNotFound) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("certificate '%s' doesn't exist", e.Code)
+	}
+	return fmt.Sprintf("enrollment '%s' has no certificate, it isn't completed", e.EnrollmentID)
+}
+
+// ErrTotalLessonsChanged is a progress event with a total of lessons other
+// than the one the first event of the enrollment fixed.
+type ErrTotalLessonsChanged struct {
+	EnrollmentID string
+	TotalLessons int
+}
+
+func (e ErrTotalLessonsChanged) Error() string {
+	return fmt.Sprintf("the course of enrollment '%s' has %d lessons", e.EnrollmentID, e.TotalLessons)
+}
+
+// ErrDependency is a failure of the user or course service other than a
+// missing record.
+type ErrDependency struct {
+	Service string
+	Err     error
+}
+
+func (e ErrDependency) Error() string {
+	return fmt.Sprintf("%s service: %v", e.Service, e.Err)
+}
+
+func (e ErrDependency) Unwrap() error {
+	return e.Err
+}
+
+// Codes of the error catalog. The clients switch on them, so they can't
+// change once released.
+const (
+	CodeInvalidRequest        = "INVALID_REQUEST"
+	CodeRequestTooLarge       = "REQUEST_TOO_LARGE"
+	CodeEnrollmentNotFound    = "ENROLLMENT_NOT_FOUND"
+	CodeUserNotFound          = "USER_NOT_FOUND"
+	CodeCourseNotFound        = "COURSE_NOT_FOUND"
+	CodeCertificateNotFound   = "CERTIFICATE_NOT_FOUND"
+	CodeAlreadyEnrolled       = "ALREADY_ENROLLED"
+	CodeTotalLessonsChanged   = "TOTAL_LESSONS_CHANGED"
+	CodeDependencyUnavailable = "DEPENDENCY_UNAVAILABLE"
+	CodeRateLimited           = "RATE_LIMITED"
+	CodeRouteNotFound         = "ROUTE_NOT_FOUND"
+	CodeMethodNotAllowed      = "METHOD_NOT_ALLOWED"
+	CodeInternal              = "INTERNAL_ERROR"
+)
+
+// ErrorResponse is the body of every error response. The message is meant
+// for people and never holds internal details, the cause is only logged.
+type ErrorResponse struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Fields lists the invalid fields of an invalid request.
+	Fields FieldErrors `json:"fields,omitempty"`
+
+	cause error
+}
+
+// NewError returns an error response of the catalog.
+func NewError(status int, code, message string) *ErrorResponse {
+	return &ErrorResponse{Status: status, Code: code, Message: message}
+}
+
+// InvalidRequest returns a bad request response, the message says what's wrong.
+func InvalidRequest(message string) *ErrorResponse {
+	return NewError(http.StatusBadRequest, CodeInvalidRequest, message)
+}
+
+// InvalidFields returns a bad request response listing the invalid fields.
+func InvalidFields(errs FieldErrors) *ErrorResponse {
+	e := InvalidRequest(errs.Error())
+	e.Fields = errs
+	return e
+}
+
+func (e *ErrorResponse) Error() string {
+	return e.Message
+}
+
+func (e *ErrorResponse) StatusCode() int {
+	return e.Status
+}
+
+func (e *ErrorResponse) GetBody() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (e *ErrorResponse) GetData() interface{} {
+	return nil
+}
+
+// Unwrap returns the error hidden from the client, nil when there's none.
+func (e *ErrorResponse) Unwrap() error {
+	return e.cause
+}
+
+// errorResponse maps an error of the service to the catalog, the same way
+// for every endpoint.
+func errorResponse(err error) *ErrorResponse {
+	var dep ErrDependency
+	switch {
+	case errors.As(err, &ErrNotFound{}):
+		return NewError(http.StatusNotFound, CodeEnrollmentNotFound, err.Error())
+	case errors.As(err, &userSdk.ErrNotFound{}):
+		return NewError(http.StatusNotFound, CodeUserNotFound, err.Error())
+	case errors.As(err, &courseSdk.ErrNotFound{}):
+		return NewError(http.StatusNotFound, CodeCourseNotFound, err.Error())
+	case errors.As(err, &ErrCertificateNotFound{}):
+		return NewError(http.StatusNotFound, CodeCertificateNotFound, err.Error())
+	case errors.Is(err, ErrAlreadyEnrolled):
+		return NewError(http.StatusConflict, CodeAlreadyEnrolled, err.Error())
+	case errors.As(err, &ErrTotalLessonsChanged{}):
+		return NewError(http.StatusConflict, CodeTotalLessonsChanged, err.Error())
+	case errors.As(err, &dep):
+		e := NewError(http.StatusServiceUnavailable, CodeDependencyUnavailable,
+			fmt.Sprintf("the %s service is unavailable, retry later", dep.Service))
+		e.cause = err
+		return e
+	}
+
+	e := NewError(http.StatusInternalServerError, CodeInternal, "internal error")
+	e.cause = err
+	return e
+}
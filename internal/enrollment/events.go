@@ -0,0 +1,183 @@
+package enrollment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+)
+
+// Types of the events published by the service.
+const (
+	EventCreated = "enrollment.created"
+	EventUpdated = "enrollment.updated"
+)
+
+type (
+	// Event is a change of an enrollment. The id is "<epoch>-<sequence>", the
+	// epoch changes when the service restarts so an id of a previous run is
+	// never taken for one of this run.
+	Event struct {
+		ID         string
+		Type       string
+		Enrollment domain.Enrollment
+
+		seq uint64
+	}
+
+	// Broker fans the events out to the subscribers without ever waiting for
+	// them, and keeps the last ones so a subscriber can resume after a
+	// disconnection. It only sees the events of this instance.
+	Broker struct {
+		mu          sync.Mutex
+		epoch       string
+		seq         uint64
+		buffer      []Event
+		bufferSize  int
+		subBuffer   int
+		subscribers map[*Subscription]struct{}
+	}
+
+	// Subscription receives the events that match its filters. C is closed
+	// when the subscriber falls behind, it must resubscribe from the last
+	// event it got, or when the subscription is closed.
+	Subscription struct {
+		C <-chan Event
+		// Replay holds the buffered events after the one the subscriber
+		// resumes from, they come before the ones sent to C.
+		Replay []Event
+		// Missed reports that events after the one the subscriber resumes
+		// from aren't buffered anymore, it has to reload the enrollments and
+		// resume from ResumeID.
+		Missed   bool
+		ResumeID string
+
+		c       chan Event
+		filters Filters
+		broker  *Broker
+	}
+)
+
+// NewBroker returns a broker that keeps the last bufferSize events and drops
+// a subscriber with more than subscriberBuffer events pending.
+func NewBroker(bufferSize, subscriberBuffer int) *Broker {
+	return &Broker{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		bufferSize:  bufferSize,
+		subBuffer:   subscriberBuffer,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// WithEvents publishes the enrollments created and updated by the service.
+func WithEvents(b *Broker) ServiceOption {
+	return func(s *service) {
+		s.events = b
+	}
+}
+
+// Publish sends the event to the subscribers interested in the enrollment.
+func (b *Broker) Publish(typ string, e domain.Enrollment) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	ev := Event{ID: b.id(b.seq), Type: typ, Enrollment: e, seq: b.seq}
+
+	if b.bufferSize > 0 {
+		if len(b.buffer) == b.bufferSize {
+			b.buffer = b.buffer[1:]
+		}
+		b.buffer = append(b.buffer, ev)
+	}
+
+	for s := range b.subscribers {
+		if !s.filters.match(e) {
+			continue
+		}
+		select {
+		case s.c <- ev:
+		default:
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe starts receiving the events that match the filters. With a last
+// event id it also replays the buffered events published after that one, an
+// id it can't parse is missed like the ids of a previous run.
+func (b *Broker) Subscribe(filters Filters, lastEventID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Event, b.subBuffer)
+	s := &Subscription{C: c, c: c, filters: filters, broker: b}
+
+	if lastEventID != "" {
+		epoch, seq, ok := parseEventID(lastEventID)
+		if !ok || epoch != b.epoch || seq > b.seq {
+			s.Missed = true
+			s.ResumeID = b.id(b.seq)
+		} else {
+			oldest := b.seq + 1
+			if len(b.buffer) > 0 {
+				oldest = b.buffer[0].seq
+			}
+			if s.Missed = seq+1 < oldest; s.Missed {
+				s.ResumeID = b.id(b.seq)
+			}
+			for _, ev := range b.buffer {
+				if ev.seq > seq && filters.match(ev.Enrollment) {
+					s.Replay = append(s.Replay, ev)
+				}
+			}
+		}
+	}
+
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// Subscribers returns the number of open subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Close stops the subscription and closes C, it can be called more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// remove must be called with the lock held.
+func (b *Broker) remove(s *Subscription) {
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.c)
+	}
+}
+
+func (b *Broker) id(seq uint64) string {
+	return fmt.Sprintf("%s-%d", b.epoch, seq)
+}
+
+func parseEventID(id string) (string, uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return "", 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return epoch, n, err == nil
+}
+
+// match reports whether the enrollment passes the user and course filters.
+func (f Filters) match(e domain.Enrollment) bool {
+	return (f.UserID == "" || e.UserID == f.UserID) &&
+		(f.CourseID == "" || e.CourseID == f.CourseID)
+}
@@ -0,0 +1,177 @@
+package enrollment_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockCourseSdk "github.com/ncostamagna/go_course_sdk/course/mock"
+	mockUserSdk "github.com/ncostamagna/go_course_sdk/user/mock"
+)
+
+// received drains the events already sent to the subscription.
+func received(s *enrollment.Subscription) []enrollment.Event {
+	var events []enrollment.Event
+	for {
+		select {
+		case ev, ok := <-s.C:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func types(events []enrollment.Event) []string {
+	res := make([]string, 0, len(events))
+	for _, ev := range events {
+		res = append(res, ev.Type+":"+ev.Enrollment.ID)
+	}
+	return res
+}
+
+func TestBroker(t *testing.T) {
+
+	t.Run("should send the events that match the filters", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		all := b.Subscribe(enrollment.Filters{}, "")
+		user := b.Subscribe(enrollment.Filters{UserID: "u-1"}, "")
+		course := b.Subscribe(enrollment.Filters{UserID: "u-1", CourseID: "c-2"}, "")
+
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "1", UserID: "u-1", CourseID: "c-1"})
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "2", UserID: "u-2", CourseID: "c-2"})
+		b.Publish(enrollment.EventUpdated, domain.Enrollment{ID: "3", UserID: "u-1", CourseID: "c-2"})
+
+		assert.Equal(t, []string{"enrollment.created:1", "enrollment.created:2", "enrollment.updated:3"}, types(received(all)))
+		assert.Equal(t, []string{"enrollment.created:1", "enrollment.updated:3"}, types(received(user)))
+		assert.Equal(t, []string{"enrollment.updated:3"}, types(received(course)))
+	})
+
+	t.Run("should replay the events after the last one received", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		first := b.Subscribe(enrollment.Filters{}, "")
+
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "1", UserID: "u-1"})
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "2", UserID: "u-2"})
+		b.Publish(enrollment.EventUpdated, domain.Enrollment{ID: "1", UserID: "u-1"})
+		last := received(first)[0].ID
+		first.Close()
+
+		s := b.Subscribe(enrollment.Filters{UserID: "u-1"}, last)
+
+		assert.False(t, s.Missed)
+		assert.Equal(t, []string{"enrollment.updated:1"}, types(s.Replay))
+
+		b.Publish(enrollment.EventUpdated, domain.Enrollment{ID: "1", UserID: "u-1"})
+		assert.Len(t, received(s), 1)
+	})
+
+	t.Run("should report the events that aren't buffered anymore", func(t *testing.T) {
+		b := enrollment.NewBroker(2, 10)
+		s := b.Subscribe(enrollment.Filters{}, "")
+
+		for _, id := range []string{"1", "2", "3", "4"} {
+			b.Publish(enrollment.EventCreated, domain.Enrollment{ID: id})
+		}
+		events := received(s)
+
+		resumed := b.Subscribe(enrollment.Filters{}, events[0].ID)
+		assert.True(t, resumed.Missed)
+		assert.Equal(t, events[3].ID, resumed.ResumeID)
+		assert.Equal(t, []string{"enrollment.created:3", "enrollment.created:4"}, types(resumed.Replay))
+
+		resumed = b.Subscribe(enrollment.Filters{}, events[1].ID)
+		assert.False(t, resumed.Missed)
+		assert.Len(t, resumed.Replay, 2)
+	})
+
+	t.Run("should report the events of a previous run as missed", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		s := b.Subscribe(enrollment.Filters{}, "abc-3")
+
+		assert.True(t, s.Missed)
+		assert.Empty(t, s.Replay)
+	})
+
+	t.Run("should report the events after an invalid event id as missed", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "1"})
+
+		for _, id := range []string{"42", "abc-x"} {
+			s := b.Subscribe(enrollment.Filters{}, id)
+			assert.True(t, s.Missed, id)
+			assert.Regexp(t, `-1$`, s.ResumeID)
+			assert.Empty(t, s.Replay)
+		}
+	})
+
+	t.Run("should drop a subscriber that falls behind without blocking", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 2)
+		slow := b.Subscribe(enrollment.Filters{}, "")
+		fast := b.Subscribe(enrollment.Filters{}, "")
+
+		// Publish returns although the slow subscriber never reads
+		var got []enrollment.Event
+		for _, id := range []string{"1", "2", "3", "4", "5"} {
+			b.Publish(enrollment.EventCreated, domain.Enrollment{ID: id})
+			got = append(got, <-fast.C)
+		}
+		assert.Equal(t, []string{"enrollment.created:1", "enrollment.created:2", "enrollment.created:3",
+			"enrollment.created:4", "enrollment.created:5"}, types(got))
+
+		assert.Len(t, received(slow), 2)
+		_, open := <-slow.C
+		assert.False(t, open)
+		assert.Equal(t, 1, b.Subscribers())
+	})
+
+	t.Run("should close a subscription once", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		s := b.Subscribe(enrollment.Filters{}, "")
+
+		s.Close()
+		s.Close()
+
+		_, open := <-s.C
+		assert.False(t, open)
+		assert.Zero(t, b.Subscribers())
+	})
+}
+
+func TestService_Events(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	users := &mockUserSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) { return &domain.User{ID: id}, nil }}
+	courses := &mockCourseSdk.CourseSdkMock{GetMock: func(id string) (*domain.Course, error) { return &domain.Course{ID: id}, nil }}
+
+	b := enrollment.NewBroker(10, 10)
+	s := b.Subscribe(enrollment.Filters{UserID: "u-1"}, "")
+
+	svc := enrollment.NewService(l, users, courses, newMemoryRepo(t), enrollment.WithEvents(b))
+
+	enroll, err := svc.Create(context.Background(), "u-1", "c-1")
+	require.NoError(t, err)
+	_, err = svc.Create(context.Background(), "u-2", "c-1")
+	require.NoError(t, err)
+
+	status := "A"
+	require.NoError(t, svc.Update(context.Background(), enroll.ID, &status))
+	require.Error(t, svc.Update(context.Background(), "missing", &status))
+
+	events := received(s)
+	require.Len(t, events, 2)
+	assert.Equal(t, enrollment.EventCreated, events[0].Type)
+	assert.Equal(t, enroll.ID, events[0].Enrollment.ID)
+	assert.Equal(t, enrollment.EventUpdated, events[1].Type)
+	assert.Equal(t, "A", events[1].Enrollment.Status)
+	assert.Equal(t, "c-1", events[1].Enrollment.CourseID)
+}
@@ -0,0 +1,151 @@
+package enrollment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+)
+
+// defaultExpandConcurrency caps the user and course calls of an expansion
+// when the service isn't given another limit.
+const defaultExpandConcurrency = 8
+
+type (
+	// Expand selects the related records embedded in the enrollments.
+	Expand struct {
+		User   bool
+		Course bool
+	}
+
+	// ExpandedEnrollment is an enrollment with its user and course. When one
+	// of them can't be fetched its error field says why.
+	ExpandedEnrollment struct {
+		domain.Enrollment
+		UserError   *ExpandError `json:"user_error,omitempty"`
+		CourseError *ExpandError `json:"course_error,omitempty"`
+	}
+
+	// ExpandError has the status, code and message of the error catalog.
+	ExpandError struct {
+		Status  int    `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	// ServiceOption configures the service built by NewService.
+	ServiceOption func(*service)
+
+	fetched[T any] struct {
+		value *T
+		err   error
+	}
+)
+
+// ParseExpand parses a comma separated list of user and course.
+func ParseExpand(s string) (Expand, error) {
+	var e Expand
+	for _, field := range strings.Split(s, ",") {
+		switch strings.TrimSpace(field) {
+		case "":
+		case "user":
+			e.User = true
+		case "course":
+			e.Course = true
+		default:
+			return Expand{}, fmt.Errorf("invalid expand '%s', it must be user or course", field)
+		}
+	}
+	return e, nil
+}
+
+// Any reports whether a related record is selected.
+func (e Expand) Any() bool {
+	return e.User || e.Course
+}
+
+// WithExpandConcurrency caps the user and course calls running at the same
+// time for one expansion.
+func WithExpandConcurrency(n int) ServiceOption {
+	return func(s *service) {
+		s.expandConcurrency = n
+	}
+}
+
+// Expand fetches each user and course of the enrollments once, concurrently.
+// A failed fetch is marked in the enrollments it belongs to.
+func (s service) Expand(ctx context.Context, enrollments []domain.Enrollment, expand Expand) []ExpandedEnrollment {
+	users := make(map[string]*fetched[domain.User])
+	courses := make(map[string]*fetched[domain.Course])
+	for _, e := range enrollments {
+		if expand.User && e.UserID != "" {
+			users[e.UserID] = &fetched[domain.User]{}
+		}
+		if expand.Course && e.CourseID != "" {
+			courses[e.CourseID] = &fetched[domain.Course]{}
+		}
+	}
+
+	limit := s.expandConcurrency
+	if limit <= 0 {
+		limit = defaultExpandConcurrency
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	// every fetch writes only its own result, so they don't need a lock
+	run := func(fn func() error, err *error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				*err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			*err = fn()
+		}()
+	}
+
+	for id, f := range users {
+		run(func() (err error) {
+			f.value, err = s.getUser(ctx, id)
+			return err
+		}, &f.err)
+	}
+	for id, f := range courses {
+		run(func() (err error) {
+			f.value, err = s.getCourse(ctx, id)
+			return err
+		}, &f.err)
+	}
+	wg.Wait()
+
+	expanded := make([]ExpandedEnrollment, len(enrollments))
+	for i, e := range enrollments {
+		expanded[i].Enrollment = e
+		if f, ok := users[e.UserID]; ok {
+			expanded[i].User, expanded[i].UserError = f.value, s.expandError(ctx, f.err)
+		}
+		if f, ok := courses[e.CourseID]; ok {
+			expanded[i].Course, expanded[i].CourseError = f.value, s.expandError(ctx, f.err)
+		}
+	}
+	return expanded
+}
+
+func (s service) expandError(ctx context.Context, err error) *ExpandError {
+	if err == nil {
+		return nil
+	}
+
+	resp := errorResponse(err)
+	if resp.Unwrap() != nil {
+		s.log.WarnContext(ctx, "expanding enrollment", "error", err)
+	}
+	return &ExpandError{Status: resp.Status, Code: resp.Code, Message: resp.Message}
+}
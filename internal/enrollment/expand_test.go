@@ -0,0 +1,207 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
+
+	mockCourseSdk "github.com/ncostamagna/go_course_sdk/course/mock"
+	mockUserSdk "github.com/ncostamagna/go_course_sdk/user/mock"
+)
+
+func TestParseExpand(t *testing.T) {
+
+	obj := []struct {
+		tag     string
+		value   string
+		want    enrollment.Expand
+		wantErr string
+	}{
+		{tag: "should expand nothing when it's empty", value: ""},
+		{tag: "should expand the user", value: "user", want: enrollment.Expand{User: true}},
+		{tag: "should expand the user and the course", value: "user, course", want: enrollment.Expand{User: true, Course: true}},
+		{tag: "should reject an unknown field", value: "user,teacher", wantErr: "invalid expand 'teacher', it must be user or course"},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := enrollment.ParseExpand(tt.value)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_Expand(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	enrolls := []domain.Enrollment{
+		{ID: "1", UserID: "u-1", CourseID: "c-1"},
+		{ID: "2", UserID: "u-1", CourseID: "c-2"},
+		{ID: "3", UserID: "u-2", CourseID: "c-1"},
+		{ID: "4", UserID: "u-3", CourseID: "c-3"},
+	}
+
+	t.Run("should fetch each user and course once", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := map[string]int{}
+		users := &mockUserSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+			mu.Lock()
+			calls[id]++
+			mu.Unlock()
+			return &domain.User{ID: id}, nil
+		}}
+		courses := &mockCourseSdk.CourseSdkMock{GetMock: func(id string) (*domain.Course, error) {
+			mu.Lock()
+			calls[id]++
+			mu.Unlock()
+			return &domain.Course{ID: id}, nil
+		}}
+
+		svc := enrollment.NewService(l, users, courses, nil)
+		got := svc.Expand(context.Background(), enrolls, enrollment.Expand{User: true, Course: true})
+
+		assert.Equal(t, map[string]int{"u-1": 1, "u-2": 1, "u-3": 1, "c-1": 1, "c-2": 1, "c-3": 1}, calls)
+		require.Len(t, got, 4)
+		for i, e := range got {
+			assert.Equal(t, enrolls[i].ID, e.ID)
+			assert.Equal(t, enrolls[i].UserID, e.User.ID)
+			assert.Equal(t, enrolls[i].CourseID, e.Course.ID)
+			assert.Nil(t, e.UserError)
+			assert.Nil(t, e.CourseError)
+		}
+	})
+
+	t.Run("should only fetch what was asked for", func(t *testing.T) {
+		users := &mockUserSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+			return &domain.User{ID: id}, nil
+		}}
+
+		svc := enrollment.NewService(l, users, nil, nil)
+		got := svc.Expand(context.Background(), enrolls, enrollment.Expand{User: true})
+
+		assert.NotNil(t, got[0].User)
+		assert.Nil(t, got[0].Course)
+	})
+
+	t.Run("should cap the concurrent calls", func(t *testing.T) {
+		var running, max int32
+		users := &mockUserSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return &domain.User{ID: id}, nil
+		}}
+
+		many := make([]domain.Enrollment, 20)
+		for i := range many {
+			many[i] = domain.Enrollment{UserID: string(rune('a' + i))}
+		}
+
+		svc := enrollment.NewService(l, users, nil, nil, enrollment.WithExpandConcurrency(3))
+		svc.Expand(context.Background(), many, enrollment.Expand{User: true})
+
+		assert.Equal(t, int32(3), atomic.LoadInt32(&max))
+	})
+
+	t.Run("should mark the items whose fetch failed", func(t *testing.T) {
+		users := &mockUserSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+			if id == "u-2" {
+				return nil, userSdk.ErrNotFound{Message: "user 'u-2' doesn't exist"}
+			}
+			return &domain.User{ID: id}, nil
+		}}
+		courses := &mockCourseSdk.CourseSdkMock{GetMock: func(id string) (*domain.Course, error) {
+			if id == "c-1" {
+				return nil, errors.New("connection refused")
+			}
+			return &domain.Course{ID: id}, nil
+		}}
+
+		svc := enrollment.NewService(l, users, courses, nil)
+		got := svc.Expand(context.Background(), enrolls, enrollment.Expand{User: true, Course: true})
+
+		assert.Equal(t, &enrollment.ExpandError{
+			Status: http.StatusNotFound, Code: enrollment.CodeUserNotFound, Message: "user 'u-2' doesn't exist",
+		}, got[2].UserError)
+		assert.Nil(t, got[2].User)
+		unavailable := &enrollment.ExpandError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    enrollment.CodeDependencyUnavailable,
+			Message: "the course service is unavailable, retry later",
+		}
+		assert.Equal(t, unavailable, got[0].CourseError)
+		assert.Equal(t, unavailable, got[2].CourseError)
+
+		assert.NotNil(t, got[1].User)
+		assert.NotNil(t, got[1].Course)
+		assert.Nil(t, got[1].CourseError)
+	})
+}
+
+func TestGetEndpoint(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repo := newMemoryRepo(t, domain.Enrollment{ID: testEnrollmentID, UserID: "u-1", CourseID: "c-1", Status: "P"})
+	users := &mockUserSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+		return &domain.User{ID: id, FirstName: "Nahuel"}, nil
+	}}
+	endpoint := enrollment.MakeEndpoints(enrollment.NewService(l, users, nil, repo), enrollment.Config{})
+
+	t.Run("should return the enrollment", func(t *testing.T) {
+		resp, err := endpoint.Get(context.Background(), enrollment.GetReq{ID: testEnrollmentID})
+		require.NoError(t, err)
+
+		r := resp.(response.Response)
+		assert.Equal(t, http.StatusOK, r.StatusCode())
+		assert.Equal(t, "u-1", r.GetData().(*domain.Enrollment).UserID)
+	})
+
+	t.Run("should return the enrollment with its user", func(t *testing.T) {
+		resp, err := endpoint.Get(context.Background(), enrollment.GetReq{ID: testEnrollmentID, Expand: "user"})
+		require.NoError(t, err)
+
+		e := resp.(response.Response).GetData().(enrollment.ExpandedEnrollment)
+		assert.Equal(t, testEnrollmentID, e.ID)
+		assert.Equal(t, "Nahuel", e.User.FirstName)
+	})
+
+	t.Run("should return not found", func(t *testing.T) {
+		_, err := endpoint.Get(context.Background(), enrollment.GetReq{ID: testMissingID})
+
+		resp := err.(response.Response)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+		assert.EqualError(t, enrollment.ErrNotFound{EnrollmentsID: testMissingID}, resp.Error())
+	})
+
+	t.Run("should return bad request with an invalid expand", func(t *testing.T) {
+		_, err := endpoint.Get(context.Background(), enrollment.GetReq{ID: testEnrollmentID, Expand: "teacher"})
+
+		resp := err.(response.Response)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+}
@@ -0,0 +1,105 @@
+package enrollment
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+)
+
+// Fields is the sparse fieldset of a list, the names are both the keys of
+// the response and the columns read. Empty selects every field.
+type Fields []string
+
+// enrollmentFields is the whitelist of the fields a client can select.
+var enrollmentFields = map[string]func(e domain.Enrollment) interface{}{
+	"id":        func(e domain.Enrollment) interface{} { return e.ID },
+	"user_id":   func(e domain.Enrollment) interface{} { return e.UserID },
+	"course_id": func(e domain.Enrollment) interface{} { return e.CourseID },
+	"status":    func(e domain.Enrollment) interface{} { return e.Status },
+}
+
+// ParseFields parses a comma separated list of enrollment fields, the
+// repeated ones are dropped.
+func ParseFields(s string) (Fields, error) {
+	var f Fields
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || f.Has(field) {
+			continue
+		}
+		if _, ok := enrollmentFields[field]; !ok {
+			return nil, fmt.Errorf("invalid field '%s', it must be id, user_id, course_id or status", field)
+		}
+		f = append(f, field)
+	}
+	return f, nil
+}
+
+// Has reports whether the field is selected.
+func (f Fields) Has(field string) bool {
+	for _, v := range f {
+		if v == field {
+			return true
+		}
+	}
+	return false
+}
+
+// With adds the fields that aren't selected yet, used to read the ids an
+// expansion needs.
+func (f Fields) With(fields ...string) Fields {
+	if len(f) == 0 {
+		return f
+	}
+	out := append(Fields{}, f...)
+	for _, field := range fields {
+		if !out.Has(field) {
+			out = append(out, field)
+		}
+	}
+	return out
+}
+
+// Select keeps the selected fields of the enrollment plus the related
+// records and errors of the expansion.
+func (f Fields) Select(e ExpandedEnrollment) map[string]interface{} {
+	m := make(map[string]interface{}, len(f)+2)
+	for _, field := range f {
+		m[field] = enrollmentFields[field](e.Enrollment)
+	}
+	if e.User != nil {
+		m["user"] = e.User
+	}
+	if e.UserError != nil {
+		m["user_error"] = e.UserError
+	}
+	if e.Course != nil {
+		m["course"] = e.Course
+	}
+	if e.CourseError != nil {
+		m["course_error"] = e.CourseError
+	}
+	return m
+}
+
+// project clears the fields that aren't selected, as a query reading only
+// their columns does.
+func (f Fields) project(e domain.Enrollment) domain.Enrollment {
+	if len(f) == 0 {
+		return e
+	}
+	return domain.Enrollment{
+		ID:       pick(f, "id", e.ID),
+		UserID:   pick(f, "user_id", e.UserID),
+		CourseID: pick(f, "course_id", e.CourseID),
+		Status:   pick(f, "status", e.Status),
+	}
+}
+
+func pick(f Fields, field, value string) string {
+	if f.Has(field) {
+		return value
+	}
+	return ""
+}
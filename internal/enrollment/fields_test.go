@@ -0,0 +1,79 @@
+package enrollment_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockUserSdk "github.com/ncostamagna/go_course_sdk/user/mock"
+)
+
+func TestParseFields(t *testing.T) {
+
+	obj := []struct {
+		tag     string
+		value   string
+		want    enrollment.Fields
+		wantErr string
+	}{
+		{tag: "should select every field when it's empty", value: ""},
+		{tag: "should keep the order of the fields", value: "status, id", want: enrollment.Fields{"status", "id"}},
+		{tag: "should drop the repeated fields", value: "id,course_id,id", want: enrollment.Fields{"id", "course_id"}},
+		{tag: "should reject a field out of the whitelist", value: "id,created_at", wantErr: "invalid field 'created_at', it must be id, user_id, course_id or status"},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := enrollment.ParseFields(tt.value)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetAllEndpoint_Fields(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repo := newMemoryRepo(t, domain.Enrollment{ID: "1", UserID: "u-1", CourseID: "c-1", Status: "P"})
+	users := &mockUserSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+		return &domain.User{ID: id, FirstName: "Nahuel"}, nil
+	}}
+	endpoint := enrollment.MakeEndpoints(enrollment.NewService(l, users, nil, repo), enrollment.Config{LimPageDef: "10"})
+
+	t.Run("should only return the selected fields", func(t *testing.T) {
+		resp, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Fields: "id,course_id,status"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []map[string]interface{}{
+			{"id": "1", "course_id": "c-1", "status": "P"},
+		}, resp.(response.Response).GetData())
+	})
+
+	t.Run("should add the expanded records to the selected fields", func(t *testing.T) {
+		resp, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Fields: "id", Expand: "user"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []map[string]interface{}{
+			{"id": "1", "user": &domain.User{ID: "u-1", FirstName: "Nahuel"}},
+		}, resp.(response.Response).GetData())
+	})
+
+	t.Run("should return bad request with an invalid field", func(t *testing.T) {
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{Fields: "password"})
+
+		resp := err.(response.Response)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+}
@@ -0,0 +1,212 @@
+package enrollment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
+
+	courseSdk "github.com/ncostamagna/go_course_sdk/course"
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
+)
+
+type (
+	// Metrics groups the instruments used by the instrumenting middlewares.
+	// RequestCount and RequestLatency are labeled by method and code,
+	// the other ones by method.
+	Metrics struct {
+		RequestCount   metrics.Counter
+		RequestLatency metrics.Histogram
+		RepoLatency    metrics.Histogram
+		RepoErrors     metrics.Counter
+		SlowQueries    metrics.Counter
+		SdkLatency     metrics.Histogram
+		SdkErrors      metrics.Counter
+	}
+
+	instrumentingRepo struct {
+		next    Repository
+		latency metrics.Histogram
+		errors  metrics.Counter
+	}
+
+	instrumentingUserTrans struct {
+		next    userSdk.Transport
+		latency metrics.Histogram
+		errors  metrics.Counter
+	}
+
+	instrumentingCourseTrans struct {
+		next    courseSdk.Transport
+		latency metrics.Histogram
+		errors  metrics.Counter
+	}
+)
+
+// InstrumentingMiddleware counts the requests and records the latency of a controller,
+// labeled by method and the status code of the response.
+func InstrumentingMiddleware(method string, counter metrics.Counter, latency metrics.Histogram) func(Controller) Controller {
+	return func(next Controller) Controller {
+		return func(ctx context.Context, request interface{}) (resp interface{}, err error) {
+			defer func(begin time.Time) {
+				lvs := []string{"method", method, "code", strconv.Itoa(statusCode(resp, err))}
+				counter.With(lvs...).Add(1)
+				latency.With(lvs...).Observe(time.Since(begin).Seconds())
+			}(time.Now())
+			return next(ctx, request)
+		}
+	}
+}
+
+// InstrumentEndpoints wraps every controller with the InstrumentingMiddleware.
+func InstrumentEndpoints(e Endpoints, m Metrics) Endpoints {
+	return Endpoints{
+		Create:              InstrumentingMiddleware("create", m.RequestCount, m.RequestLatency)(e.Create),
+		Get:                 InstrumentingMiddleware("get", m.RequestCount, m.RequestLatency)(e.Get),
+		GetAll:              InstrumentingMiddleware("get_all", m.RequestCount, m.RequestLatency)(e.GetAll),
+		Update:              InstrumentingMiddleware("update", m.RequestCount, m.RequestLatency)(e.Update),
+		RecordProgress:      InstrumentingMiddleware("record_progress", m.RequestCount, m.RequestLatency)(e.RecordProgress),
+		GetProgress:         InstrumentingMiddleware("get_progress", m.RequestCount, m.RequestLatency)(e.GetProgress),
+		GetCertificate:      InstrumentingMiddleware("get_certificate", m.RequestCount, m.RequestLatency)(e.GetCertificate),
+		DownloadCertificate: InstrumentingMiddleware("download_certificate", m.RequestCount, m.RequestLatency)(e.DownloadCertificate),
+		VerifyCertificate:   InstrumentingMiddleware("verify_certificate", m.RequestCount, m.RequestLatency)(e.VerifyCertificate),
+	}
+}
+
+func statusCode(resp interface{}, err error) int {
+	var r response.Response
+	if err != nil {
+		if errors.As(err, &r) {
+			return r.StatusCode()
+		}
+		return http.StatusInternalServerError
+	}
+
+	if r, ok := resp.(response.Response); ok {
+		return r.StatusCode()
+	}
+	return http.StatusOK
+}
+
+// NewInstrumentingRepo records the latency and the errors of every repository call.
+func NewInstrumentingRepo(next Repository, latency metrics.Histogram, errors metrics.Counter) Repository {
+	return &instrumentingRepo{
+		next:    next,
+		latency: latency,
+		errors:  errors,
+	}
+}
+
+func (r *instrumentingRepo) observe(method string, begin time.Time, err error) {
+	r.latency.With("method", method).Observe(time.Since(begin).Seconds())
+	if err != nil {
+		r.errors.With("method", method).Add(1)
+	}
+}
+
+func (r *instrumentingRepo) Create(ctx context.Context, enroll *domain.Enrollment) (err error) {
+	defer func(begin time.Time) { r.observe("create", begin, err) }(time.Now())
+	return r.next.Create(ctx, enroll)
+}
+
+func (r *instrumentingRepo) Get(ctx context.Context, id string) (e *domain.Enrollment, err error) {
+	defer func(begin time.Time) { r.observe("get", begin, err) }(time.Now())
+	return r.next.Get(ctx, id)
+}
+
+func (r *instrumentingRepo) GetAll(ctx context.Context, filters Filters, offset, limit int) (e []domain.Enrollment, err error) {
+	defer func(begin time.Time) { r.observe("get_all", begin, err) }(time.Now())
+	return r.next.GetAll(ctx, filters, offset, limit)
+}
+
+func (r *instrumentingRepo) Update(ctx context.Context, id string, status *string) (err error) {
+	defer func(begin time.Time) { r.observe("update", begin, err) }(time.Now())
+	return r.next.Update(ctx, id, status)
+}
+
+func (r *instrumentingRepo) Count(ctx context.Context, filters Filters) (c int, err error) {
+	defer func(begin time.Time) { r.observe("count", begin, err) }(time.Now())
+	return r.next.Count(ctx, filters)
+}
+
+func (r *instrumentingRepo) LastUpdated(ctx context.Context, filters Filters) (t *time.Time, err error) {
+	defer func(begin time.Time) { r.observe("last_updated", begin, err) }(time.Now())
+	return r.next.LastUpdated(ctx, filters)
+}
+
+func (r *instrumentingRepo) RecordProgress(ctx context.Context, id, lessonID string, totalLessons int, at time.Time) (err error) {
+	defer func(begin time.Time) { r.observe("record_progress", begin, err) }(time.Now())
+	return r.next.RecordProgress(ctx, id, lessonID, totalLessons, at)
+}
+
+func (r *instrumentingRepo) GetProgress(ctx context.Context, id string) (p *Progress, err error) {
+	defer func(begin time.Time) { r.observe("get_progress", begin, err) }(time.Now())
+	return r.next.GetProgress(ctx, id)
+}
+
+func (r *instrumentingRepo) CreateCertificate(ctx context.Context, c *Certificate) (err error) {
+	defer func(begin time.Time) { r.observe("create_certificate", begin, err) }(time.Now())
+	return r.next.CreateCertificate(ctx, c)
+}
+
+func (r *instrumentingRepo) GetCertificate(ctx context.Context, enrollmentID string) (c *Certificate, err error) {
+	defer func(begin time.Time) { r.observe("get_certificate", begin, err) }(time.Now())
+	return r.next.GetCertificate(ctx, enrollmentID)
+}
+
+func (r *instrumentingRepo) GetCertificateByCode(ctx context.Context, code string) (c *Certificate, err error) {
+	defer func(begin time.Time) { r.observe("get_certificate_by_code", begin, err) }(time.Now())
+	return r.next.GetCertificateByCode(ctx, code)
+}
+
+// NewInstrumentingUserTransport records the latency and the errors of the user service calls.
+func NewInstrumentingUserTransport(next userSdk.Transport, latency metrics.Histogram, errors metrics.Counter) UserTransport {
+	return &instrumentingUserTrans{
+		next:    next,
+		latency: latency,
+		errors:  errors,
+	}
+}
+
+func (t *instrumentingUserTrans) Get(id string) (*domain.User, error) {
+	return t.GetContext(context.Background(), id)
+}
+
+// GetContext passes the context on when the next transport takes it.
+func (t *instrumentingUserTrans) GetContext(ctx context.Context, id string) (u *domain.User, err error) {
+	defer func(begin time.Time) {
+		t.latency.With("method", "user_get").Observe(time.Since(begin).Seconds())
+		if err != nil {
+			t.errors.With("method", "user_get").Add(1)
+		}
+	}(time.Now())
+	return getUserContext(ctx, t.next, id)
+}
+
+// NewInstrumentingCourseTransport records the latency and the errors of the course service calls.
+func NewInstrumentingCourseTransport(next courseSdk.Transport, latency metrics.Histogram, errors metrics.Counter) CourseTransport {
+	return &instrumentingCourseTrans{
+		next:    next,
+		latency: latency,
+		errors:  errors,
+	}
+}
+
+func (t *instrumentingCourseTrans) Get(id string) (*domain.Course, error) {
+	return t.GetContext(context.Background(), id)
+}
+
+func (t *instrumentingCourseTrans) GetContext(ctx context.Context, id string) (c *domain.Course, err error) {
+	defer func(begin time.Time) {
+		t.latency.With("method", "course_get").Observe(time.Since(begin).Seconds())
+		if err != nil {
+			t.errors.With("method", "course_get").Add(1)
+		}
+	}(time.Now())
+	return getCourseContext(ctx, t.next, id)
+}
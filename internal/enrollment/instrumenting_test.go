@@ -0,0 +1,120 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+
+	mockUserSdk "github.com/ncostamagna/go_course_sdk/user/mock"
+)
+
+type recorder struct {
+	mu     *sync.Mutex
+	lvs    []string
+	values map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{mu: &sync.Mutex{}, values: map[string]int{}}
+}
+
+func (r *recorder) With(labelValues ...string) metrics.Counter {
+	return &recorder{mu: r.mu, lvs: append(append([]string{}, r.lvs...), labelValues...), values: r.values}
+}
+
+func (r *recorder) Add(float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[strings.Join(r.lvs, ",")]++
+}
+
+func (r *recorder) Observe(float64) {
+	r.Add(1)
+}
+
+type histogramRecorder struct {
+	*recorder
+}
+
+func (h histogramRecorder) With(labelValues ...string) metrics.Histogram {
+	return histogramRecorder{h.recorder.With(labelValues...).(*recorder)}
+}
+
+func TestInstrumentingMiddleware(t *testing.T) {
+
+	obj := []struct {
+		tag       string
+		resp      interface{}
+		err       error
+		wantLabel string
+	}{
+		{tag: "should label a success response with its status", resp: response.Created("success", nil, nil), wantLabel: "method,create,code,201"},
+		{tag: "should label an error response with its status", err: response.NotFound("not found"), wantLabel: "method,create,code,404"},
+		{tag: "should label an unknown error as internal server error", err: errors.New("unexpected error"), wantLabel: "method,create,code,500"},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			counter := newRecorder()
+			latency := histogramRecorder{newRecorder()}
+
+			c := enrollment.InstrumentingMiddleware("create", counter, latency)(func(ctx context.Context, request interface{}) (interface{}, error) {
+				return tt.resp, tt.err
+			})
+			resp, err := c(context.Background(), nil)
+
+			assert.Equal(t, tt.resp, resp)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, map[string]int{tt.wantLabel: 1}, counter.values)
+			assert.Equal(t, map[string]int{tt.wantLabel: 1}, latency.values)
+		})
+	}
+}
+
+func TestInstrumentingRepo(t *testing.T) {
+
+	latency := histogramRecorder{newRecorder()}
+	errCounter := newRecorder()
+
+	repo := enrollment.NewInstrumentingRepo(&mockRepository{
+		CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+			return nil
+		},
+		CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+			return 0, errors.New("unexpected error")
+		},
+	}, latency, errCounter)
+
+	assert.NoError(t, repo.Create(context.Background(), &domain.Enrollment{}))
+	_, err := repo.Count(context.Background(), enrollment.Filters{})
+	assert.Error(t, err)
+
+	assert.Equal(t, map[string]int{"method,create": 1, "method,count": 1}, latency.values)
+	assert.Equal(t, map[string]int{"method,count": 1}, errCounter.values)
+}
+
+func TestInstrumentingUserTransport(t *testing.T) {
+
+	latency := histogramRecorder{newRecorder()}
+	errCounter := newRecorder()
+
+	trans := enrollment.NewInstrumentingUserTransport(&mockUserSdk.UserSdkMock{
+		GetMock: func(id string) (*domain.User, error) {
+			return nil, errors.New("unexpected error")
+		},
+	}, latency, errCounter)
+
+	_, err := trans.Get("1")
+	assert.Error(t, err)
+
+	assert.Equal(t, map[string]int{"method,user_get": 1}, latency.values)
+	assert.Equal(t, map[string]int{"method,user_get": 1}, errCounter.values)
+}
@@ -0,0 +1,242 @@
+package enrollment
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ncostamagna/gocourse_domain/domain"
+)
+
+// memoryRepo keeps the enrollments in memory, it's meant for local development
+// and tests. It's safe for concurrent use.
+type memoryRepo struct {
+	mu           sync.RWMutex
+	enrollments  map[string]domain.Enrollment
+	progress     map[string]progressRecord
+	lessons      map[string][]lessonRecord
+	certificates map[string]Certificate // by enrollment id
+}
+
+// NewMemoryRepo returns an empty in-memory repository.
+func NewMemoryRepo() Repository {
+	return &memoryRepo{
+		enrollments:  make(map[string]domain.Enrollment),
+		progress:     make(map[string]progressRecord),
+		lessons:      make(map[string][]lessonRecord),
+		certificates: make(map[string]Certificate),
+	}
+}
+
+func (r *memoryRepo) Create(ctx context.Context, enroll *domain.Enrollment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if enroll.ID == "" {
+		enroll.ID = uuid.New().String()
+	}
+	if _, ok := r.enrollments[enroll.ID]; ok {
+		return fmt.Errorf("enrollment '%s' already exists", enroll.ID)
+	}
+	for _, e := range r.enrollments {
+		if e.UserID == enroll.UserID && e.CourseID == enroll.CourseID {
+			return ErrAlreadyEnrolled
+		}
+	}
+
+	now := time.Now().UTC()
+	if enroll.CreatedAt == nil {
+		enroll.CreatedAt = &now
+	}
+	if enroll.UpdatedAt == nil {
+		enroll.UpdatedAt = &now
+	}
+
+	r.enrollments[enroll.ID] = clone(*enroll)
+	return nil
+}
+
+func (r *memoryRepo) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.enrollments[id]
+	if !ok {
+		return nil, ErrNotFound{id}
+	}
+	e = clone(e)
+	return &e, nil
+}
+
+// GetAll orders like the SQL repository: newest first and then by id. A
+// negative limit or offset is ignored, as GORM does.
+func (r *memoryRepo) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error) {
+	r.mu.RLock()
+	e := r.filter(filters)
+	r.mu.RUnlock()
+
+	sort.Slice(e, func(i, j int) bool {
+		if !e[i].CreatedAt.Equal(*e[j].CreatedAt) {
+			return e[i].CreatedAt.After(*e[j].CreatedAt)
+		}
+		return e[i].ID > e[j].ID
+	})
+
+	if offset > 0 {
+		e = e[min(offset, len(e)):]
+	}
+	if limit >= 0 {
+		e = e[:min(limit, len(e))]
+	}
+	for i := range e {
+		e[i] = filters.Fields.project(e[i])
+	}
+	return e, nil
+}
+
+func (r *memoryRepo) Update(ctx context.Context, id string, status *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.enrollments[id]
+	if !ok {
+		return ErrNotFound{id}
+	}
+
+	// like gorm, the update time moves forward even without a status
+	now := time.Now().UTC()
+	if status != nil {
+		e.Status = *status
+	}
+	e.UpdatedAt = &now
+	r.enrollments[id] = e
+	return nil
+}
+
+func (r *memoryRepo) Count(ctx context.Context, filters Filters) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.filter(filters)), nil
+}
+
+func (r *memoryRepo) LastUpdated(ctx context.Context, filters Filters) (*time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var last *time.Time
+	for _, e := range r.filter(filters) {
+		if e.UpdatedAt != nil && (last == nil || e.UpdatedAt.After(*last)) {
+			last = e.UpdatedAt
+		}
+	}
+	return last, nil
+}
+
+func (r *memoryRepo) RecordProgress(ctx context.Context, id, lessonID string, totalLessons int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rec, ok := r.progress[id]; ok && rec.TotalLessons != totalLessons {
+		return ErrTotalLessonsChanged{EnrollmentID: id, TotalLessons: rec.TotalLessons}
+	}
+	r.progress[id] = progressRecord{EnrollmentID: id, TotalLessons: totalLessons, LastActivityAt: &at}
+	for _, l := range r.lessons[id] {
+		if l.LessonID == lessonID {
+			return nil
+		}
+	}
+	r.lessons[id] = append(r.lessons[id], lessonRecord{EnrollmentID: id, LessonID: lessonID, CompletedAt: at})
+	return nil
+}
+
+// GetProgress orders the lessons like the SQL repository: by completion time
+// and then by id.
+func (r *memoryRepo) GetProgress(ctx context.Context, id string) (*Progress, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lessons := append([]lessonRecord(nil), r.lessons[id]...)
+	sort.Slice(lessons, func(i, j int) bool {
+		if !lessons[i].CompletedAt.Equal(lessons[j].CompletedAt) {
+			return lessons[i].CompletedAt.Before(lessons[j].CompletedAt)
+		}
+		return lessons[i].LessonID < lessons[j].LessonID
+	})
+
+	rec, ok := r.progress[id]
+	if !ok {
+		return newProgress(id, nil, lessons), nil
+	}
+	return newProgress(id, &rec, lessons), nil
+}
+
+func (r *memoryRepo) CreateCertificate(ctx context.Context, c *Certificate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.certificates[c.EnrollmentID]; ok {
+		return fmt.Errorf("enrollment '%s' already has a certificate", c.EnrollmentID)
+	}
+	for _, other := range r.certificates {
+		if other.Code == c.Code {
+			return fmt.Errorf("certificate '%s' already exists", c.Code)
+		}
+	}
+
+	r.certificates[c.EnrollmentID] = *c
+	return nil
+}
+
+func (r *memoryRepo) GetCertificate(ctx context.Context, enrollmentID string) (*Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.certificates[enrollmentID]
+	if !ok {
+		return nil, ErrCertificateNotFound{EnrollmentID: enrollmentID}
+	}
+	return &c, nil
+}
+
+func (r *memoryRepo) GetCertificateByCode(ctx context.Context, code string) (*Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.certificates {
+		if c.Code == code {
+			return &c, nil
+		}
+	}
+	return nil, ErrCertificateNotFound{Code: code}
+}
+
+// filter returns copies of the enrollments that match, so the callers can't
+// modify the stored ones. The caller must hold the lock.
+func (r *memoryRepo) filter(filters Filters) []domain.Enrollment {
+	e := make([]domain.Enrollment, 0, len(r.enrollments))
+	for _, enroll := range r.enrollments {
+		if filters.match(enroll) {
+			e = append(e, clone(enroll))
+		}
+	}
+	return e
+}
+
+// clone copies the enrollment without the user and course, which the SQL
+// repository doesn't store either.
+func clone(e domain.Enrollment) domain.Enrollment {
+	e.User, e.Course = nil, nil
+	if e.CreatedAt != nil {
+		t := *e.CreatedAt
+		e.CreatedAt = &t
+	}
+	if e.UpdatedAt != nil {
+		t := *e.UpdatedAt
+		e.UpdatedAt = &t
+	}
+	return e
+}
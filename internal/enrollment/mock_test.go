@@ -2,22 +2,46 @@ package enrollment_test
 
 import (
 	"context"
+	"testing"
+	"time"
 
 	"github.com/ncostamagna/gocourse_domain/domain"
 	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
 )
 
+// UUIDs of the requests sent to the endpoints, which validate their format.
+const (
+	testEnrollmentID = "9b2e6c1a-4f0d-4c8e-9a57-3d1f0b6e2a41"
+	testMissingID    = "0d6f3a8e-1c2b-4e7a-8f90-5b4c3d2e1f00"
+	testUserID       = "3c9a7e52-8d1b-4f6a-b2e0-7a5d9c1e4b83"
+	testCourseID     = "e1f4b8d2-6a3c-4d9e-8b7f-2c5a1e9d6f34"
+)
+
 type mockRepository struct {
 	CreateMock func(ctx context.Context, enroll *domain.Enrollment) error
+	GetMock    func(ctx context.Context, id string) (*domain.Enrollment, error)
 	GetAllMock func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error)
 	UpdateMock func(ctx context.Context, id string, status *string) error
 	CountMock  func(ctx context.Context, filters enrollment.Filters) (int, error)
+
+	LastUpdatedMock func(ctx context.Context, filters enrollment.Filters) (*time.Time, error)
+
+	RecordProgressMock func(ctx context.Context, id, lessonID string, totalLessons int, at time.Time) error
+	GetProgressMock    func(ctx context.Context, id string) (*enrollment.Progress, error)
+
+	CreateCertificateMock    func(ctx context.Context, c *enrollment.Certificate) error
+	GetCertificateMock       func(ctx context.Context, enrollmentID string) (*enrollment.Certificate, error)
+	GetCertificateByCodeMock func(ctx context.Context, code string) (*enrollment.Certificate, error)
 }
 
 func (m *mockRepository) Create(ctx context.Context, enroll *domain.Enrollment) error {
 	return m.CreateMock(ctx, enroll)
 }
 
+func (m *mockRepository) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	return m.GetMock(ctx, id)
+}
+
 func (m *mockRepository) GetAll(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
 	return m.GetAllMock(ctx, filters, offset, limit)
 }
@@ -29,3 +53,45 @@ func (m *mockRepository) Update(ctx context.Context, id string, status *string)
 func (m *mockRepository) Count(ctx context.Context, filters enrollment.Filters) (int, error) {
 	return m.CountMock(ctx, filters)
 }
+
+func (m *mockRepository) LastUpdated(ctx context.Context, filters enrollment.Filters) (*time.Time, error) {
+	return m.LastUpdatedMock(ctx, filters)
+}
+
+func (m *mockRepository) RecordProgress(ctx context.Context, id, lessonID string, totalLessons int, at time.Time) error {
+	return m.RecordProgressMock(ctx, id, lessonID, totalLessons, at)
+}
+
+func (m *mockRepository) GetProgress(ctx context.Context, id string) (*enrollment.Progress, error) {
+	return m.GetProgressMock(ctx, id)
+}
+
+func (m *mockRepository) CreateCertificate(ctx context.Context, c *enrollment.Certificate) error {
+	return m.CreateCertificateMock(ctx, c)
+}
+
+func (m *mockRepository) GetCertificate(ctx context.Context, enrollmentID string) (*enrollment.Certificate, error) {
+	return m.GetCertificateMock(ctx, enrollmentID)
+}
+
+func (m *mockRepository) GetCertificateByCode(ctx context.Context, code string) (*enrollment.Certificate, error) {
+	return m.GetCertificateByCodeMock(ctx, code)
+}
+
+// newMemoryRepo returns an in-memory repository holding the enrollments, the
+// first one being the oldest.
+func newMemoryRepo(t *testing.T, enrolls ...domain.Enrollment) enrollment.Repository {
+	t.Helper()
+
+	repo := enrollment.NewMemoryRepo()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range enrolls {
+		createdAt := base.Add(time.Duration(i) * time.Minute)
+		enrolls[i].CreatedAt = &createdAt
+		enrolls[i].UpdatedAt = &createdAt
+		if err := repo.Create(context.Background(), &enrolls[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
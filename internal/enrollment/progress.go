@@ -0,0 +1,63 @@
+package enrollment
+
+import (
+	"time"
+)
+
+type (
+	// Progress is how far the student of an enrollment is in the course,
+	// aggregated from the progress events. The course service doesn't know
+	// the lessons of a course, so the first event fixes their total and the
+	// later ones must send the same.
+	Progress struct {
+		EnrollmentID string `json:"enrollment_id"`
+		// Status is the status of the enrollment, StatusCompleted once the
+		// percent reaches 100.
+		Status           string     `json:"status"`
+		Percent          int        `json:"percent"`
+		CompletedLessons []string   `json:"completed_lessons"`
+		TotalLessons     int        `json:"total_lessons"`
+		LastActivityAt   *time.Time `json:"last_activity_at,omitempty"`
+	}
+
+	// progressRecord and lessonRecord are the rows of the progress tables.
+	progressRecord struct {
+		EnrollmentID   string `gorm:"primaryKey"`
+		TotalLessons   int
+		LastActivityAt *time.Time
+	}
+
+	lessonRecord struct {
+		EnrollmentID string `gorm:"primaryKey"`
+		LessonID     string `gorm:"primaryKey"`
+		CompletedAt  time.Time
+	}
+)
+
+func (progressRecord) TableName() string { return "enrollment_progress" }
+
+func (lessonRecord) TableName() string { return "enrollment_lessons" }
+
+// newProgress aggregates the progress of an enrollment, the lessons ordered
+// by completion. An enrollment without events has no progress record.
+func newProgress(id string, rec *progressRecord, lessons []lessonRecord) *Progress {
+	p := &Progress{EnrollmentID: id, CompletedLessons: make([]string, 0, len(lessons))}
+	for _, l := range lessons {
+		p.CompletedLessons = append(p.CompletedLessons, l.LessonID)
+	}
+	if rec != nil {
+		p.TotalLessons = rec.TotalLessons
+		p.LastActivityAt = rec.LastActivityAt
+		p.Percent = percent(len(lessons), rec.TotalLessons)
+	}
+	return p
+}
+
+// percent rounds down, so only completing every lesson reaches 100. The
+// events can complete more lessons than the total.
+func percent(completed, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return min(completed*100/total, 100)
+}
@@ -0,0 +1,61 @@
+package enrollment
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type (
+	// Replica is a read replica for GetAll and Count. When a query fails on it
+	// the reads go to the primary until the retry interval elapses.
+	Replica struct {
+		db    *gorm.DB
+		retry time.Duration
+
+		mu      sync.Mutex
+		retryAt time.Time
+	}
+
+	primaryKey struct{}
+)
+
+func NewReplica(db *gorm.DB, retry time.Duration) *Replica {
+	return &Replica{
+		db:    db,
+		retry: retry,
+	}
+}
+
+// WithReplica sends the list and count queries to the replica.
+func WithReplica(r *Replica) RepoOption {
+	return func(repo *repo) {
+		repo.replica = r
+	}
+}
+
+// ReadFromPrimary makes the repository read from the primary, for the callers
+// that must see their own writes before they reach the replica.
+func ReadFromPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// reader returns the replica, or nil while it's considered unhealthy.
+func (r *Replica) reader() *gorm.DB {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Now().Before(r.retryAt) {
+		return nil
+	}
+	return r.db
+}
+
+func (r *Replica) fail() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.retryAt = time.Now().Add(r.retry)
+}
@@ -2,59 +2,153 @@ package enrollment
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"time"
 
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-sql-driver/mysql"
 	"github.com/ncostamagna/gocourse_domain/domain"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// mysqlDuplicateEntry is the error number of MySQL for a duplicated key.
+const mysqlDuplicateEntry = 1062
+
 type (
 	Repository interface {
+		// Create returns ErrAlreadyEnrolled when the user is already enrolled
+		// in the course.
 		Create(ctx context.Context, enroll *domain.Enrollment) error
+		Get(ctx context.Context, id string) (*domain.Enrollment, error)
 		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error)
 		Update(ctx context.Context, id string, status *string) error
 		Count(ctx context.Context, filters Filters) (int, error)
+		// LastUpdated returns the latest update time of the enrollments that
+		// match, nil when none does.
+		LastUpdated(ctx context.Context, filters Filters) (*time.Time, error)
+		// RecordProgress stores a completed lesson of the enrollment, once.
+		// The first event fixes the total lessons of its course, a later one
+		// with another total fails with ErrTotalLessonsChanged. The caller
+		// checks that the enrollment exists.
+		RecordProgress(ctx context.Context, id, lessonID string, totalLessons int, at time.Time) error
+		// GetProgress returns the progress of the enrollment without its
+		// status, an empty one when no event was recorded.
+		GetProgress(ctx context.Context, id string) (*Progress, error)
+		// CreateCertificate fails when the enrollment already has one.
+		CreateCertificate(ctx context.Context, c *Certificate) error
+		GetCertificate(ctx context.Context, enrollmentID string) (*Certificate, error)
+		GetCertificateByCode(ctx context.Context, code string) (*Certificate, error)
 	}
 
 	repo struct {
-		db  *gorm.DB
-		log *log.Logger
+		db            *gorm.DB
+		replica       *Replica
+		log           *slog.Logger
+		queryTimeout  time.Duration
+		slowThreshold time.Duration
+		slowQueries   metrics.Counter
 	}
+
+	// RepoOption configures the repository built by NewRepo.
+	RepoOption func(*repo)
 )
 
 // NewRepo is a repositories handler
-func NewRepo(db *gorm.DB, l *log.Logger) Repository {
-	return &repo{
+func NewRepo(db *gorm.DB, l *slog.Logger, opts ...RepoOption) Repository {
+	r := &repo{
 		db:  db,
 		log: l,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithQueryTimeout bounds every repository call, on top of the deadline of
+// the request context.
+func WithQueryTimeout(d time.Duration) RepoOption {
+	return func(r *repo) {
+		r.queryTimeout = d
+	}
+}
+
+// WithSlowQueries logs the calls that take longer than threshold and counts
+// them by method, the counter may be nil.
+func WithSlowQueries(threshold time.Duration, counter metrics.Counter) RepoOption {
+	return func(r *repo) {
+		r.slowThreshold = threshold
+		r.slowQueries = counter
+	}
 }
 
 func (r *repo) Create(ctx context.Context, enroll *domain.Enrollment) error {
+	ctx, end := r.begin(ctx, "Create")
+	defer end()
 
 	if err := r.db.WithContext(ctx).Create(enroll).Error; err != nil {
-		r.log.Println(err)
+		if isDuplicatedKey(r.db, err) {
+			return ErrAlreadyEnrolled
+		}
+		r.log.ErrorContext(ctx, "creating enrollment", "error", err,
+			"user_id", enroll.UserID, "course_id", enroll.CourseID)
 		return err
 	}
 	return nil
 }
 
+// Get reads from the primary, it's used right after the writes.
+func (r *repo) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	ctx, end := r.begin(ctx, "Get")
+	defer end()
+
+	var e domain.Enrollment
+	result := r.db.WithContext(ctx).
+		Where(clause.Eq{Column: clause.Column{Name: "id"}, Value: id}).Limit(1).Find(&e)
+	if result.Error != nil {
+		r.log.ErrorContext(ctx, "getting enrollment", "error", result.Error, "enrollment_id", id)
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound{id}
+	}
+
+	return &e, nil
+}
+
 func (r *repo) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error) {
+	ctx, end := r.begin(ctx, "GetAll")
+	defer end()
+
 	var e []domain.Enrollment
 
-	tx := r.db.WithContext(ctx).Model(&e)
-	tx = applyFilters(tx, filters)
-	tx = tx.Limit(limit).Offset(offset)
-	result := tx.Order("created_at desc").Find(&e)
+	err := r.read(ctx, func(db *gorm.DB) error {
+		e = nil
+		tx := db.Model(&e)
+		tx = applyFilters(tx, filters)
+		if len(filters.Fields) > 0 {
+			tx = tx.Select([]string(filters.Fields))
+		}
+		tx = tx.Limit(limit).Offset(offset)
+		return tx.Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true}).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: true}).
+			Find(&e).Error
+	})
 
-	if result.Error != nil {
-		r.log.Println(result.Error)
-		return nil, result.Error
+	if err != nil {
+		r.log.ErrorContext(ctx, "getting enrollments", "error", err,
+			"user_id", filters.UserID, "course_id", filters.CourseID)
+		return nil, err
 	}
 	return e, nil
 }
 
 func (r *repo) Update(ctx context.Context, id string, status *string) error {
+	ctx, end := r.begin(ctx, "Update")
+	defer end()
 
 	values := make(map[string]interface{})
 
@@ -62,14 +156,15 @@ func (r *repo) Update(ctx context.Context, id string, status *string) error {
 		values["status"] = *status
 	}
 
-	result := r.db.WithContext(ctx).Model(&domain.Enrollment{}).Where("id = ?", id).Updates(values)
+	result := r.db.WithContext(ctx).Model(&domain.Enrollment{}).
+		Where(clause.Eq{Column: clause.Column{Name: "id"}, Value: id}).Updates(values)
 	if result.Error != nil {
-		r.log.Println(result.Error)
+		r.log.ErrorContext(ctx, "updating enrollment", "error", result.Error, "enrollment_id", id)
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		r.log.Printf("enrollment %s doesn't exists", id)
+		r.log.WarnContext(ctx, "enrollment doesn't exist", "enrollment_id", id)
 		return ErrNotFound{id}
 	}
 
@@ -77,25 +172,220 @@ func (r *repo) Update(ctx context.Context, id string, status *string) error {
 }
 
 func (r *repo) Count(ctx context.Context, filters Filters) (int, error) {
+	ctx, end := r.begin(ctx, "Count")
+	defer end()
+
 	var count int64
-	tx := r.db.WithContext(ctx).Model(domain.Enrollment{})
-	tx = applyFilters(tx, filters)
-	if err := tx.Count(&count).Error; err != nil {
-		r.log.Println(err)
+	err := r.read(ctx, func(db *gorm.DB) error {
+		tx := db.Model(domain.Enrollment{})
+		tx = applyFilters(tx, filters)
+		return tx.Count(&count).Error
+	})
+	if err != nil {
+		r.log.ErrorContext(ctx, "counting enrollments", "error", err,
+			"user_id", filters.UserID, "course_id", filters.CourseID)
 		return 0, err
 	}
 
 	return int(count), nil
 }
 
+func (r *repo) LastUpdated(ctx context.Context, filters Filters) (*time.Time, error) {
+	ctx, end := r.begin(ctx, "LastUpdated")
+	defer end()
+
+	// ordering instead of MAX lets GORM parse the column for every driver
+	var e []domain.Enrollment
+	err := r.read(ctx, func(db *gorm.DB) error {
+		e = nil
+		tx := db.Model(&e).Select("updated_at")
+		tx = applyFilters(tx, filters)
+		return tx.Order(clause.OrderByColumn{Column: clause.Column{Name: "updated_at"}, Desc: true}).
+			Limit(1).Find(&e).Error
+	})
+	if err != nil {
+		r.log.ErrorContext(ctx, "getting the last update of the enrollments", "error", err,
+			"user_id", filters.UserID, "course_id", filters.CourseID)
+		return nil, err
+	}
+
+	if len(e) == 0 {
+		return nil, nil
+	}
+	return e[0].UpdatedAt, nil
+}
+
+func (r *repo) RecordProgress(ctx context.Context, id, lessonID string, totalLessons int, at time.Time) error {
+	ctx, end := r.begin(ctx, "RecordProgress")
+	defer end()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&progressRecord{EnrollmentID: id, TotalLessons: totalLessons, LastActivityAt: &at}).Error
+		if err != nil {
+			return err
+		}
+
+		byID := clause.Eq{Column: clause.Column{Name: "enrollment_id"}, Value: id}
+		var rec progressRecord
+		if err := tx.Where(byID).Take(&rec).Error; err != nil {
+			return err
+		}
+		if rec.TotalLessons != totalLessons {
+			return ErrTotalLessonsChanged{EnrollmentID: id, TotalLessons: rec.TotalLessons}
+		}
+		if err := tx.Model(&progressRecord{}).Where(byID).Update("last_activity_at", at).Error; err != nil {
+			return err
+		}
+
+		// a lesson completed again keeps its first completion time
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&lessonRecord{EnrollmentID: id, LessonID: lessonID, CompletedAt: at}).Error
+	})
+	if errors.As(err, &ErrTotalLessonsChanged{}) {
+		return err
+	}
+	if err != nil {
+		r.log.ErrorContext(ctx, "recording progress", "error", err, "enrollment_id", id, "lesson_id", lessonID)
+		return err
+	}
+	return nil
+}
+
+// GetProgress reads from the primary, it's used right after RecordProgress.
+func (r *repo) GetProgress(ctx context.Context, id string) (*Progress, error) {
+	ctx, end := r.begin(ctx, "GetProgress")
+	defer end()
+
+	byID := clause.Eq{Column: clause.Column{Name: "enrollment_id"}, Value: id}
+
+	var rec []progressRecord
+	var lessons []lessonRecord
+	err := r.db.WithContext(ctx).Where(byID).Limit(1).Find(&rec).Error
+	if err == nil {
+		err = r.db.WithContext(ctx).Where(byID).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: "completed_at"}}).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: "lesson_id"}}).
+			Find(&lessons).Error
+	}
+	if err != nil {
+		r.log.ErrorContext(ctx, "getting progress", "error", err, "enrollment_id", id)
+		return nil, err
+	}
+
+	if len(rec) == 0 {
+		return newProgress(id, nil, lessons), nil
+	}
+	return newProgress(id, &rec[0], lessons), nil
+}
+
+func (r *repo) CreateCertificate(ctx context.Context, c *Certificate) error {
+	ctx, end := r.begin(ctx, "CreateCertificate")
+	defer end()
+
+	if err := r.db.WithContext(ctx).Create(c).Error; err != nil {
+		r.log.ErrorContext(ctx, "creating certificate", "error", err, "enrollment_id", c.EnrollmentID)
+		return err
+	}
+	return nil
+}
+
+func (r *repo) GetCertificate(ctx context.Context, enrollmentID string) (*Certificate, error) {
+	ctx, end := r.begin(ctx, "GetCertificate")
+	defer end()
+
+	return r.findCertificate(ctx, "enrollment_id", enrollmentID, ErrCertificateNotFound{EnrollmentID: enrollmentID})
+}
+
+func (r *repo) GetCertificateByCode(ctx context.Context, code string) (*Certificate, error) {
+	ctx, end := r.begin(ctx, "GetCertificateByCode")
+	defer end()
+
+	return r.findCertificate(ctx, "code", code, ErrCertificateNotFound{Code: code})
+}
+
+// findCertificate reads from the primary, a certificate is read right after
+// it's issued.
+func (r *repo) findCertificate(ctx context.Context, column, value string, notFound error) (*Certificate, error) {
+	var c Certificate
+	result := r.db.WithContext(ctx).
+		Where(clause.Eq{Column: clause.Column{Name: column}, Value: value}).Limit(1).Find(&c)
+	if result.Error != nil {
+		r.log.ErrorContext(ctx, "getting certificate", "error", result.Error, column, value)
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, notFound
+	}
+	return &c, nil
+}
+
+// begin applies the query timeout to the context. The returned function must
+// be called when the call ends, it reports the call if it was slow.
+func (r *repo) begin(ctx context.Context, method string) (context.Context, func()) {
+	start := time.Now()
+	cancel := context.CancelFunc(func() {})
+	if r.queryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.queryTimeout)
+	}
+
+	return ctx, func() {
+		cancel()
+		if r.slowThreshold <= 0 {
+			return
+		}
+		if d := time.Since(start); d >= r.slowThreshold {
+			r.log.WarnContext(ctx, "slow query", "method", method, "duration", d)
+			if r.slowQueries != nil {
+				r.slowQueries.With("method", method).Add(1)
+			}
+		}
+	}
+}
+
+// read runs the query on the replica, if any, and retries it on the primary
+// when the replica fails.
+func (r *repo) read(ctx context.Context, query func(db *gorm.DB) error) error {
+	if r.replica != nil && ctx.Value(primaryKey{}) == nil {
+		if db := r.replica.reader(); db != nil {
+			err := query(db.WithContext(ctx))
+			if err == nil || errors.Is(ctx.Err(), context.Canceled) {
+				return err
+			}
+			// a replica that doesn't answer within the deadline is unhealthy
+			// too, but there's no time left to retry on the primary
+			r.replica.fail()
+			if ctx.Err() != nil {
+				return err
+			}
+			r.log.WarnContext(ctx, "read replica failed, reading from the primary", "error", err)
+		}
+	}
+
+	return query(r.db.WithContext(ctx))
+}
+
+// isDuplicatedKey reports whether err breaks a unique index. The postgres and
+// sqlite dialectors translate their errors, the mysql one doesn't.
+func isDuplicatedKey(db *gorm.DB, err error) bool {
+	if t, ok := db.Dialector.(gorm.ErrorTranslator); ok && errors.Is(t.Translate(err), gorm.ErrDuplicatedKey) {
+		return true
+	}
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == mysqlDuplicateEntry
+}
+
+// applyFilters uses clause expressions so GORM quotes the columns and binds the
+// values for every driver.
 func applyFilters(tx *gorm.DB, filters Filters) *gorm.DB {
 
 	if filters.UserID != "" {
-		tx = tx.Where("user_id = ?", filters.UserID)
+		tx = tx.Where(clause.Eq{Column: clause.Column{Name: "user_id"}, Value: filters.UserID})
 	}
 
 	if filters.CourseID != "" {
-		tx = tx.Where("course_id = ?", filters.CourseID)
+		tx = tx.Where(clause.Eq{Column: clause.Column{Name: "course_id"}, Value: filters.CourseID})
 	}
 
 	return tx
@@ -0,0 +1,249 @@
+package enrollment_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment/enrollmenttest"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/bootstrap"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := bootstrap.DBConnection(config.Database{
+		Driver:  "sqlite",
+		Name:    filepath.Join(t.TempDir(), "enrollments.db"),
+		Migrate: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bootstrap.CloseDB(db) })
+
+	return db
+}
+
+// TestRepo runs the contract against a SQLite database file created with the
+// real migrations.
+func TestRepo(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	enrollmenttest.RepositoryContract(t, func(t *testing.T) enrollment.Repository {
+		return enrollment.NewRepo(newSQLiteDB(t), l)
+	})
+
+	t.Run("with a replica", func(t *testing.T) {
+		enrollmenttest.RepositoryContract(t, func(t *testing.T) enrollment.Repository {
+			db := newSQLiteDB(t)
+			return enrollment.NewRepo(db, l, enrollment.WithReplica(enrollment.NewReplica(db, time.Minute)))
+		})
+	})
+}
+
+// TestRepoPostgres reads back what it stores in the Postgres database at
+// TEST_POSTGRES_HOST, created with the real migrations. It's skipped without
+// one.
+func TestRepoPostgres(t *testing.T) {
+	host := os.Getenv("TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Skip("TEST_POSTGRES_HOST isn't set")
+	}
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	db, err := bootstrap.DBConnection(config.Database{
+		Driver:   "postgres",
+		Host:     host,
+		User:     os.Getenv("TEST_POSTGRES_USER"),
+		Password: os.Getenv("TEST_POSTGRES_PASSWORD"),
+		Name:     os.Getenv("TEST_POSTGRES_DB"),
+		SSLMode:  "disable",
+		Migrate:  true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bootstrap.CloseDB(db) })
+	repo := enrollment.NewRepo(db, l)
+
+	t.Run("should read back the status as it was stored", func(t *testing.T) {
+		e := &domain.Enrollment{UserID: uuid.NewString(), CourseID: uuid.NewString(), Status: enrollment.StatusPending}
+		require.NoError(t, repo.Create(ctx, e))
+		t.Cleanup(func() { db.Delete(&domain.Enrollment{}, "id = ?", e.ID) })
+
+		got, err := repo.Get(ctx, e.ID)
+		require.NoError(t, err)
+		assert.Equal(t, enrollment.StatusPending, got.Status)
+
+		status := enrollment.StatusCompleted
+		require.NoError(t, repo.Update(ctx, e.ID, &status))
+		all, err := repo.GetAll(ctx, enrollment.Filters{UserID: e.UserID}, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, enrollment.StatusCompleted, all[0].Status)
+	})
+}
+
+func TestRepoReplica(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	t.Run("should read from the replica and write to the primary", func(t *testing.T) {
+		primary, replica := newSQLiteDB(t), newSQLiteDB(t)
+		repo := enrollment.NewRepo(primary, l, enrollment.WithReplica(enrollment.NewReplica(replica, time.Minute)))
+
+		require.NoError(t, repo.Create(ctx, &domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"}))
+		require.NoError(t, replica.Create(&domain.Enrollment{UserID: "u-2", CourseID: "c-1", Status: "P"}).Error)
+
+		got, err := repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "u-2", got[0].UserID)
+
+		count, err := repo.Count(ctx, enrollment.Filters{UserID: "u-1"})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("should read from the primary when asked to", func(t *testing.T) {
+		primary, replica := newSQLiteDB(t), newSQLiteDB(t)
+		repo := enrollment.NewRepo(primary, l, enrollment.WithReplica(enrollment.NewReplica(replica, time.Minute)))
+
+		require.NoError(t, repo.Create(ctx, &domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"}))
+
+		count, err := repo.Count(enrollment.ReadFromPrimary(ctx), enrollment.Filters{UserID: "u-1"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("should fall back to the primary while the replica is unhealthy", func(t *testing.T) {
+		primary, replica := newSQLiteDB(t), newSQLiteDB(t)
+		r := enrollment.NewReplica(replica, 50*time.Millisecond)
+		repo := enrollment.NewRepo(primary, l, enrollment.WithReplica(r))
+
+		require.NoError(t, repo.Create(ctx, &domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"}))
+		require.NoError(t, replica.Migrator().RenameTable("enrollments", "enrollments_off"))
+
+		count, err := repo.Count(ctx, enrollment.Filters{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		// the replica is back, but the reads stay on the primary until the retry
+		require.NoError(t, replica.Migrator().RenameTable("enrollments_off", "enrollments"))
+		got, err := repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, got, 1)
+
+		time.Sleep(60 * time.Millisecond)
+		got, err = repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
+
+func TestMemoryRepo(t *testing.T) {
+	enrollmenttest.RepositoryContract(t, func(t *testing.T) enrollment.Repository {
+		return enrollment.NewMemoryRepo()
+	})
+
+	t.Run("should support concurrent use", func(t *testing.T) {
+		repo := enrollment.NewMemoryRepo()
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e := &domain.Enrollment{UserID: "u-1", CourseID: fmt.Sprintf("c-%d", i), Status: "P"}
+				assert.NoError(t, repo.Create(ctx, e))
+				status := "A"
+				assert.NoError(t, repo.Update(ctx, e.ID, &status))
+				_, err := repo.GetAll(ctx, enrollment.Filters{UserID: "u-1"}, 0, 10)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		count, err := repo.Count(ctx, enrollment.Filters{UserID: "u-1"})
+		require.NoError(t, err)
+		assert.Equal(t, 50, count)
+	})
+
+	t.Run("should not expose the stored enrollments", func(t *testing.T) {
+		repo := enrollment.NewMemoryRepo()
+		ctx := context.Background()
+
+		e := &domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"}
+		require.NoError(t, repo.Create(ctx, e))
+		e.Status = "A"
+
+		got, err := repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+		got[0].Status = "S"
+
+		got, err = repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, "P", got[0].Status)
+	})
+}
+
+func TestRepoQueryTimeout(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	t.Run("should cancel the calls that exceed the timeout", func(t *testing.T) {
+		repo := enrollment.NewRepo(newSQLiteDB(t), l, enrollment.WithQueryTimeout(time.Nanosecond))
+
+		_, err := repo.Count(ctx, enrollment.Filters{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should not cancel the calls within the timeout", func(t *testing.T) {
+		repo := enrollment.NewRepo(newSQLiteDB(t), l, enrollment.WithQueryTimeout(time.Minute))
+
+		require.NoError(t, repo.Create(ctx, &domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"}))
+		count, err := repo.Count(ctx, enrollment.Filters{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestRepoSlowQueries(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	t.Run("should count the calls above the threshold", func(t *testing.T) {
+		counter := newRecorder()
+		repo := enrollment.NewRepo(newSQLiteDB(t), l, enrollment.WithSlowQueries(time.Nanosecond, counter))
+
+		require.NoError(t, repo.Create(ctx, &domain.Enrollment{UserID: "u-1", CourseID: "c-1", Status: "P"}))
+		_, err := repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+		_, err = repo.GetAll(ctx, enrollment.Filters{}, 0, 10)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]int{"method,Create": 1, "method,GetAll": 2}, counter.values)
+	})
+
+	t.Run("should not count the calls below the threshold", func(t *testing.T) {
+		counter := newRecorder()
+		repo := enrollment.NewRepo(newSQLiteDB(t), l, enrollment.WithSlowQueries(time.Minute, counter))
+
+		_, err := repo.Count(ctx, enrollment.Filters{})
+		require.NoError(t, err)
+
+		assert.Empty(t, counter.values)
+	})
+}
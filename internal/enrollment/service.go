@@ -2,9 +2,14 @@ package enrollment
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/ncostamagna/gocourse_domain/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	courseSdk "github.com/ncostamagna/go_course_sdk/course"
 	userSdk "github.com/ncostamagna/go_course_sdk/user"
@@ -14,63 +19,133 @@ type (
 	Filters struct {
 		UserID   string
 		CourseID string
+		// Fields limits the columns GetAll reads, every column when empty.
+		Fields Fields
 	}
 
 	Service interface {
 		Create(ctx context.Context, userID, courseID string) (*domain.Enrollment, error)
+		Get(ctx context.Context, id string) (*domain.Enrollment, error)
 		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error)
 		Update(ctx context.Context, id string, status *string) error
 		Count(ctx context.Context, filters Filters) (int, error)
+		LastUpdated(ctx context.Context, filters Filters) (*time.Time, error)
+		Expand(ctx context.Context, enrollments []domain.Enrollment, expand Expand) []ExpandedEnrollment
+		RecordProgress(ctx context.Context, id, lessonID string, totalLessons int) (*Progress, error)
+		GetProgress(ctx context.Context, id string) (*Progress, error)
+		GetCertificate(ctx context.Context, id string) (*Certificate, error)
+		VerifyCertificate(ctx context.Context, code string) (*Certificate, error)
 	}
 
 	service struct {
-		log         *log.Logger
-		userTrans   userSdk.Transport
-		courseTrans courseSdk.Transport
-		repo        Repository
+		log               *slog.Logger
+		userTrans         userSdk.Transport
+		courseTrans       courseSdk.Transport
+		repo              Repository
+		expandConcurrency int
+		events            *Broker
 	}
 )
 
-func NewService(l *log.Logger, userTrans userSdk.Transport, courseTrans courseSdk.Transport, repo Repository) Service {
-	return &service{
+func NewService(l *slog.Logger, userTrans userSdk.Transport, courseTrans courseSdk.Transport, repo Repository, opts ...ServiceOption) Service {
+	s := &service{
 		log:         l,
 		userTrans:   userTrans,
 		courseTrans: courseTrans,
 		repo:        repo,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s service) Create(ctx context.Context, userID, courseID string) (*domain.Enrollment, error) {
+func (s service) Create(ctx context.Context, userID, courseID string) (_ *domain.Enrollment, err error) {
+	ctx, span := tracer().Start(ctx, "service.Create")
+	span.SetAttributes(attribute.String("enrollment.user_id", userID), attribute.String("enrollment.course_id", courseID))
+	defer func() {
+		endSpan(span, err)
+		span.End()
+	}()
 
 	enroll := &domain.Enrollment{
 		UserID:   userID,
 		CourseID: courseID,
-		Status:   "P",
+		Status:   StatusPending,
 	}
 
-	if _, err := s.userTrans.Get(userID); err != nil {
+	if _, err := s.getUser(ctx, userID); err != nil {
 		return nil, err
 	}
 
-	if _, err := s.courseTrans.Get(courseID); err != nil {
+	if _, err := s.getCourse(ctx, courseID); err != nil {
+		return nil, err
+	}
+
+	// the check and the insert aren't atomic, the unique index of the
+	// repository rejects the second of two concurrent requests. It reads
+	// from the primary, an enrollment just created may not have reached the
+	// replica.
+	count, err := s.repo.Count(ReadFromPrimary(ctx), Filters{UserID: userID, CourseID: courseID})
+	if err != nil {
 		return nil, err
 	}
+	if count > 0 {
+		return nil, ErrAlreadyEnrolled
+	}
 
 	if err := s.repo.Create(ctx, enroll); err != nil {
 		return nil, err
 	}
 
-	s.log.Println("[SUCCESS] Service - Create - enrollments")
+	s.log.InfoContext(ctx, "enrollment created",
+		"enrollment_id", enroll.ID, "user_id", userID, "course_id", courseID)
+
+	if s.events != nil {
+		s.events.Publish(EventCreated, *enroll)
+	}
 	return enroll, nil
 }
 
+// getUser and getCourse trace the SDK calls, the transports that take a
+// context send the trace context to the services. A failure other than a
+// missing record is returned as ErrDependency.
+func (s service) getUser(ctx context.Context, id string) (*domain.User, error) {
+	ctx, span := tracer().Start(ctx, "userTrans.Get", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	u, err := getUserContext(ctx, s.userTrans, id)
+	endSpan(span, err)
+	if err != nil && !errors.As(err, &userSdk.ErrNotFound{}) {
+		return nil, ErrDependency{Service: "user", Err: err}
+	}
+	return u, err
+}
+
+func (s service) getCourse(ctx context.Context, id string) (*domain.Course, error) {
+	ctx, span := tracer().Start(ctx, "courseTrans.Get", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	c, err := getCourseContext(ctx, s.courseTrans, id)
+	endSpan(span, err)
+	if err != nil && !errors.As(err, &courseSdk.ErrNotFound{}) {
+		return nil, ErrDependency{Service: "course", Err: err}
+	}
+	return c, err
+}
+
+func (s service) Get(ctx context.Context, id string) (*domain.Enrollment, error) {
+	return s.repo.Get(ctx, id)
+}
+
 func (s service) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.Enrollment, error) {
 	enrollments, err := s.repo.GetAll(ctx, filters, offset, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	s.log.Println("[SUCCESS] Service - GetAll - enrollments")
+	s.log.DebugContext(ctx, "enrollments listed",
+		"user_id", filters.UserID, "course_id", filters.CourseID, "count", len(enrollments))
 	return enrollments, nil
 }
 
@@ -80,10 +155,143 @@ func (s service) Update(ctx context.Context, id string, status *string) error {
 		return err
 	}
 
-	s.log.Println("[SUCCESS] Service - Update - enrollments")
+	s.log.InfoContext(ctx, "enrollment updated", "enrollment_id", id)
+
+	completed := status != nil && *status == StatusCompleted
+	if s.events == nil && !completed {
+		return nil
+	}
+
+	// the subscribers filter by user and course, and the certificate is
+	// issued to them, which the update doesn't have
+	enroll, err := s.repo.Get(ReadFromPrimary(ctx), id)
+	if err != nil {
+		s.log.WarnContext(ctx, "enrollment update not published", "enrollment_id", id, "error", err)
+		return nil
+	}
+	if s.events != nil {
+		s.events.Publish(EventUpdated, *enroll)
+	}
+
+	// the update is done, a certificate that can't be issued now is issued
+	// when it's first requested
+	if completed {
+		if _, err := s.issueCertificate(ctx, *enroll); err != nil {
+			s.log.WarnContext(ctx, "certificate not issued", "enrollment_id", id, "error", err)
+		}
+	}
 	return nil
 }
 
 func (s service) Count(ctx context.Context, filters Filters) (int, error) {
 	return s.repo.Count(ctx, filters)
 }
+
+func (s service) LastUpdated(ctx context.Context, filters Filters) (*time.Time, error) {
+	return s.repo.LastUpdated(ctx, filters)
+}
+
+// RecordProgress records a completed lesson and completes the enrollment once
+// every lesson is. When the update fails the progress is kept, the next event
+// completes it.
+func (s service) RecordProgress(ctx context.Context, id, lessonID string, totalLessons int) (*Progress, error) {
+	enroll, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RecordProgress(ctx, id, lessonID, totalLessons, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	p, err := s.repo.GetProgress(ReadFromPrimary(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	p.Status = enroll.Status
+
+	s.log.InfoContext(ctx, "progress recorded",
+		"enrollment_id", id, "lesson_id", lessonID, "percent", p.Percent)
+
+	if p.Percent == 100 && enroll.Status != StatusCompleted {
+		status := StatusCompleted
+		if err := s.Update(ctx, id, &status); err != nil {
+			return nil, err
+		}
+		p.Status = status
+	}
+	return p, nil
+}
+
+func (s service) GetProgress(ctx context.Context, id string) (*Progress, error) {
+	enroll, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.repo.GetProgress(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p.Status = enroll.Status
+	return p, nil
+}
+
+// GetCertificate returns the certificate of a completed enrollment, issuing it
+// when it couldn't be issued on completion.
+func (s service) GetCertificate(ctx context.Context, id string) (*Certificate, error) {
+	enroll, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if enroll.Status != StatusCompleted {
+		return nil, ErrCertificateNotFound{EnrollmentID: id}
+	}
+	return s.issueCertificate(ctx, *enroll)
+}
+
+func (s service) VerifyCertificate(ctx context.Context, code string) (*Certificate, error) {
+	return s.repo.GetCertificateByCode(ctx, NormalizeCertificateCode(code))
+}
+
+// issueCertificate returns the certificate of the enrollment, creating it
+// with the student name and the course title when it has none.
+func (s service) issueCertificate(ctx context.Context, enroll domain.Enrollment) (*Certificate, error) {
+	// the certificate may have just been issued by another request
+	ctx = ReadFromPrimary(ctx)
+
+	c, err := s.repo.GetCertificate(ctx, enroll.ID)
+	if !errors.As(err, &ErrCertificateNotFound{}) {
+		return c, err
+	}
+
+	user, err := s.getUser(ctx, enroll.UserID)
+	if err != nil {
+		return nil, err
+	}
+	course, err := s.getCourse(ctx, enroll.CourseID)
+	if err != nil {
+		return nil, err
+	}
+
+	c = &Certificate{
+		EnrollmentID: enroll.ID,
+		Code:         newCertificateCode(),
+		UserID:       enroll.UserID,
+		CourseID:     enroll.CourseID,
+		StudentName:  strings.TrimSpace(user.FirstName + " " + user.LastName),
+		CourseTitle:  course.Name,
+		IssuedAt:     time.Now().UTC(),
+	}
+	if err := s.repo.CreateCertificate(ctx, c); err != nil {
+		// another request issued it first
+		if issued, getErr := s.repo.GetCertificate(ctx, enroll.ID); getErr == nil {
+			return issued, nil
+		}
+		return nil, err
+	}
+
+	s.log.InfoContext(ctx, "certificate issued", "enrollment_id", enroll.ID, "code", c.Code)
+	return c, nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
+	"strings"
 	"testing"
+	"time"
 
 	courseSdk "github.com/ncostamagna/go_course_sdk/course/mock"
 	userSdk "github.com/ncostamagna/go_course_sdk/user/mock"
@@ -14,11 +16,12 @@ import (
 
 	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestService_GetAll(t *testing.T) {
 
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	t.Run("should return an error", func(t *testing.T) {
 		var want error = errors.New("my error")
@@ -42,44 +45,29 @@ func TestService_GetAll(t *testing.T) {
 	})
 
 	t.Run("should return all enrollments", func(t *testing.T) {
-		want := []domain.Enrollment{
-			{
-				ID:       "1",
-				UserID:   "11",
-				CourseID: "22",
-				Status:   "P",
-			},
-		}
-		var wantCounter int = 1
-		var counter int = 0
-		repo := &mockRepository{
-			GetAllMock: func(ctx context.Context, filters enrollment.Filters, offset, limit int) ([]domain.Enrollment, error) {
-				counter++
-				return []domain.Enrollment{
-					{
-						ID:       "1",
-						UserID:   "11",
-						CourseID: "22",
-						Status:   "P",
-					},
-				}, nil
-			},
-		}
+		repo := newMemoryRepo(t, domain.Enrollment{
+			ID:       "1",
+			UserID:   "11",
+			CourseID: "22",
+			Status:   "P",
+		})
 
 		service := enrollment.NewService(l, nil, nil, repo)
 
 		enrollments, err := service.GetAll(context.Background(), enrollment.Filters{}, 0, 10)
 
 		assert.Nil(t, err)
-		assert.NotNil(t, enrollments)
-		assert.Equal(t, wantCounter, counter)
-		assert.Equal(t, want, enrollments)
+		assert.Len(t, enrollments, 1)
+		assert.Equal(t, "1", enrollments[0].ID)
+		assert.Equal(t, "11", enrollments[0].UserID)
+		assert.Equal(t, "22", enrollments[0].CourseID)
+		assert.Equal(t, "P", enrollments[0].Status)
 
 	})
 }
 
 func TestService_Update(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	t.Run("should return an error", func(t *testing.T) {
 		var want error = errors.New("my error")
@@ -103,32 +91,31 @@ func TestService_Update(t *testing.T) {
 	})
 
 	t.Run("should update an enrollment", func(t *testing.T) {
-		var wantCounter int = 1
-		var counter int = 0
-		var wantStatus string = "A"
-		var wantID string = "11"
-		repo := &mockRepository{
-			UpdateMock: func(ctx context.Context, id string, status *string) error {
-				counter++
-				assert.Equal(t, wantID, id)
-				assert.NotNil(t, status)
-				assert.Equal(t, wantStatus, *status)
-				return nil
-			},
-		}
+		repo := newMemoryRepo(t, domain.Enrollment{ID: "11", UserID: "1", CourseID: "2", Status: "P"})
 
 		service := enrollment.NewService(l, nil, nil, repo)
 
 		status := "A"
 		err := service.Update(context.Background(), "11", &status)
+		assert.Nil(t, err)
 
+		enrollments, err := repo.GetAll(context.Background(), enrollment.Filters{}, 0, 10)
 		assert.Nil(t, err)
-		assert.Equal(t, wantCounter, counter)
+		assert.Equal(t, "A", enrollments[0].Status)
+	})
+
+	t.Run("should return not found when the enrollment doesn't exist", func(t *testing.T) {
+		service := enrollment.NewService(l, nil, nil, newMemoryRepo(t))
+
+		status := "A"
+		err := service.Update(context.Background(), "11", &status)
+
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentsID: "11"}, err)
 	})
 }
 
 func TestService_Count(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	t.Run("should return an error", func(t *testing.T) {
 		var want error = errors.New("my error")
@@ -152,31 +139,26 @@ func TestService_Count(t *testing.T) {
 	})
 
 	t.Run("should return the count of enrollments", func(t *testing.T) {
-		var wantCounter int = 1
-		var counter int = 0
-		var want int = 10
-		repo := &mockRepository{
-			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
-				counter++
-				return 10, nil
-			},
-		}
+		repo := newMemoryRepo(t,
+			domain.Enrollment{UserID: "11", CourseID: "22", Status: "P"},
+			domain.Enrollment{UserID: "11", CourseID: "33", Status: "P"},
+			domain.Enrollment{UserID: "44", CourseID: "22", Status: "P"},
+		)
 
 		service := enrollment.NewService(l, nil, nil, repo)
 
-		count, err := service.Count(context.Background(), enrollment.Filters{})
+		count, err := service.Count(context.Background(), enrollment.Filters{UserID: "11"})
 
 		assert.Nil(t, err)
-		assert.Equal(t, wantCounter, counter)
-		assert.Equal(t, want, count)
+		assert.Equal(t, 2, count)
 	})
 }
 
 func TestService_Create(t *testing.T) {
-	l := log.New(io.Discard, "", 0)
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
 
 	t.Run("should return an error in user sdk", func(t *testing.T) {
-		var want error = errors.New("my error")
+		var want error = errors.New("user service: my error")
 		var wantCounter int = 1
 		var counter int = 0
 		userSdk := &userSdk.UserSdkMock{
@@ -197,7 +179,7 @@ func TestService_Create(t *testing.T) {
 	})
 
 	t.Run("should return an error in course sdk", func(t *testing.T) {
-		var want error = errors.New("my error")
+		var want error = errors.New("course service: my error")
 		var wantCounter int = 2
 		var counter int = 0
 		userSdk := &userSdk.UserSdkMock{
@@ -243,6 +225,9 @@ func TestService_Create(t *testing.T) {
 		}
 
 		repo := &mockRepository{
+			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+				return 0, nil
+			},
 			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
 				counter++
 				return errors.New("my error")
@@ -260,12 +245,11 @@ func TestService_Create(t *testing.T) {
 	})
 
 	t.Run("should create an enrollment", func(t *testing.T) {
-		var wantCounter int = 3
+		var wantCounter int = 2
 		var counter int = 0
 		var wantUserID string = "11"
 		var wantCourseID string = "22"
 		var wantStatus string = "P"
-		var wantID string = "123"
 		userSdk := &userSdk.UserSdkMock{
 			GetMock: func(id string) (*domain.User, error) {
 				counter++
@@ -280,25 +264,297 @@ func TestService_Create(t *testing.T) {
 				return nil, nil
 			},
 		}
+		repo := newMemoryRepo(t)
+
+		service := enrollment.NewService(l, userSdk, courseSdk, repo)
+
+		enroll, err := service.Create(context.Background(), "11", "22")
+
+		assert.Nil(t, err)
+		assert.Equal(t, wantCounter, counter)
+		assert.NotNil(t, enroll)
+		assert.NotEmpty(t, enroll.ID)
+
+		stored, err := repo.GetAll(context.Background(), enrollment.Filters{}, 0, 10)
+		assert.Nil(t, err)
+		assert.Len(t, stored, 1)
+		assert.Equal(t, enroll.ID, stored[0].ID)
+
+		assert.Equal(t, wantUserID, enroll.UserID)
+		assert.Equal(t, wantCourseID, enroll.CourseID)
+		assert.Equal(t, wantStatus, enroll.Status)
+	})
+
+	t.Run("should return an error if the user is already enrolled", func(t *testing.T) {
+		sdkUser := &userSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}
+		sdkCourse := &courseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}
+		repo := newMemoryRepo(t, domain.Enrollment{UserID: "11", CourseID: "22", Status: "P"})
+
+		service := enrollment.NewService(l, sdkUser, sdkCourse, repo)
+
+		enroll, err := service.Create(context.Background(), "11", "22")
+
+		assert.ErrorIs(t, err, enrollment.ErrAlreadyEnrolled)
+		assert.Nil(t, enroll)
+
+		count, err := repo.Count(context.Background(), enrollment.Filters{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+}
+
+func TestService_RecordProgress(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	t.Run("should return not found when the enrollment doesn't exist", func(t *testing.T) {
+		service := enrollment.NewService(l, nil, nil, newMemoryRepo(t))
+
+		_, err := service.RecordProgress(ctx, "11", "l-1", 2)
+
+		assert.Equal(t, enrollment.ErrNotFound{EnrollmentsID: "11"}, err)
+	})
+
+	t.Run("should record the progress without completing the enrollment", func(t *testing.T) {
+		repo := newMemoryRepo(t, domain.Enrollment{ID: "11", UserID: "1", CourseID: "2", Status: "S"})
+		service := enrollment.NewService(l, nil, nil, repo)
+
+		p, err := service.RecordProgress(ctx, "11", "l-1", 2)
+		require.NoError(t, err)
+
+		assert.Equal(t, "S", p.Status)
+		assert.Equal(t, 50, p.Percent)
+		assert.Equal(t, []string{"l-1"}, p.CompletedLessons)
+		assert.NotNil(t, p.LastActivityAt)
+
+		got, err := service.GetProgress(ctx, "11")
+		require.NoError(t, err)
+		assert.Equal(t, p, got)
+	})
+
+	t.Run("should complete the enrollment at 100 percent", func(t *testing.T) {
+		repo := newMemoryRepo(t, domain.Enrollment{ID: "11", UserID: "1", CourseID: "2", Status: "S"})
+		b := enrollment.NewBroker(10, 10)
+		sub := b.Subscribe(enrollment.Filters{}, "")
+		service := enrollment.NewService(l, testUsers(), testCourses(), repo, enrollment.WithEvents(b))
+
+		_, err := service.RecordProgress(ctx, "11", "l-1", 2)
+		require.NoError(t, err)
+		p, err := service.RecordProgress(ctx, "11", "l-2", 2)
+		require.NoError(t, err)
+
+		assert.Equal(t, 100, p.Percent)
+		assert.Equal(t, enrollment.StatusCompleted, p.Status)
+
+		e, err := repo.Get(ctx, "11")
+		require.NoError(t, err)
+		assert.Equal(t, enrollment.StatusCompleted, e.Status)
+
+		ev := <-sub.C
+		assert.Equal(t, enrollment.EventUpdated, ev.Type)
+		assert.Equal(t, enrollment.StatusCompleted, ev.Enrollment.Status)
+
+		c, err := repo.GetCertificate(ctx, "11")
+		require.NoError(t, err)
+		assert.Equal(t, "Nahuel Costamagna", c.StudentName)
+	})
+
+	t.Run("should not complete the enrollment with a smaller total of lessons", func(t *testing.T) {
+		repo := newMemoryRepo(t, domain.Enrollment{ID: "11", UserID: "1", CourseID: "2", Status: "S"})
+		service := enrollment.NewService(l, testUsers(), testCourses(), repo)
+
+		_, err := service.RecordProgress(ctx, "11", "l-1", 12)
+		require.NoError(t, err)
+		_, err = service.RecordProgress(ctx, "11", "l-2", 1)
+		assert.Equal(t, enrollment.ErrTotalLessonsChanged{EnrollmentID: "11", TotalLessons: 12}, err)
+
+		p, err := service.GetProgress(ctx, "11")
+		require.NoError(t, err)
+		assert.Equal(t, "S", p.Status)
+		assert.Equal(t, 8, p.Percent)
+
+		_, err = service.GetCertificate(ctx, "11")
+		assert.Equal(t, enrollment.ErrCertificateNotFound{EnrollmentID: "11"}, err)
+	})
+
+	t.Run("should not update a completed enrollment again", func(t *testing.T) {
 		repo := &mockRepository{
-			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
-				counter++
-				enroll.ID = "123"
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: enrollment.StatusCompleted}, nil
+			},
+			RecordProgressMock: func(ctx context.Context, id, lessonID string, totalLessons int, at time.Time) error {
 				return nil
 			},
+			GetProgressMock: func(ctx context.Context, id string) (*enrollment.Progress, error) {
+				return &enrollment.Progress{EnrollmentID: id, Percent: 100}, nil
+			},
 		}
+		service := enrollment.NewService(l, nil, nil, repo)
 
-		service := enrollment.NewService(l, userSdk, courseSdk, repo)
+		p, err := service.RecordProgress(ctx, "11", "l-1", 1)
+		require.NoError(t, err)
+		assert.Equal(t, enrollment.StatusCompleted, p.Status)
+	})
 
-		enrollment, err := service.Create(context.Background(), "11", "22")
+	t.Run("should return an error of the repository", func(t *testing.T) {
+		repo := &mockRepository{
+			GetMock: func(ctx context.Context, id string) (*domain.Enrollment, error) {
+				return &domain.Enrollment{ID: id, Status: "S"}, nil
+			},
+			RecordProgressMock: func(ctx context.Context, id, lessonID string, totalLessons int, at time.Time) error {
+				return errors.New("my error")
+			},
+		}
+		service := enrollment.NewService(l, nil, nil, repo)
 
-		assert.Nil(t, err)
-		assert.Equal(t, wantCounter, counter)
-		assert.NotNil(t, enrollment)
-		assert.Equal(t, wantID, enrollment.ID)
-		assert.Equal(t, wantUserID, enrollment.UserID)
-		assert.Equal(t, wantCourseID, enrollment.CourseID)
-		assert.Equal(t, wantStatus, enrollment.Status)
+		_, err := service.RecordProgress(ctx, "11", "l-1", 1)
+		assert.EqualError(t, err, "my error")
+	})
+}
+
+func TestService_Certificate(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	t.Run("should issue the certificate when the enrollment is completed", func(t *testing.T) {
+		repo := newMemoryRepo(t, domain.Enrollment{ID: "11", UserID: "1", CourseID: "2", Status: "S"})
+		service := enrollment.NewService(l, testUsers(), testCourses(), repo)
+
+		status := enrollment.StatusCompleted
+		require.NoError(t, service.Update(ctx, "11", &status))
+
+		c, err := service.GetCertificate(ctx, "11")
+		require.NoError(t, err)
+		assert.Equal(t, "11", c.EnrollmentID)
+		assert.Equal(t, "1", c.UserID)
+		assert.Equal(t, "2", c.CourseID)
+		assert.Equal(t, "Nahuel Costamagna", c.StudentName)
+		assert.Equal(t, "Go", c.CourseTitle)
+		assert.Regexp(t, `^[0-9A-Z]{4}(-[0-9A-Z]{4}){3}$`, c.Code)
+
+		verified, err := service.VerifyCertificate(ctx, strings.ToLower(c.Code))
+		require.NoError(t, err)
+		assert.Equal(t, c, verified)
+	})
+
+	t.Run("should issue the certificate on request when the services failed on completion", func(t *testing.T) {
+		repo := newMemoryRepo(t, domain.Enrollment{ID: "11", UserID: "1", CourseID: "2", Status: "S"})
+		calls := 0
+		users := &userSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &domain.User{ID: id, FirstName: "Nahuel"}, nil
+		}}
+		service := enrollment.NewService(l, users, testCourses(), repo)
+
+		status := enrollment.StatusCompleted
+		require.NoError(t, service.Update(ctx, "11", &status))
+		_, err := repo.GetCertificate(ctx, "11")
+		require.Error(t, err)
+
+		c, err := service.GetCertificate(ctx, "11")
+		require.NoError(t, err)
+		assert.Equal(t, "Nahuel", c.StudentName)
+
+		again, err := service.GetCertificate(ctx, "11")
+		require.NoError(t, err)
+		assert.Equal(t, c.Code, again.Code)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("should not issue a certificate before the enrollment is completed", func(t *testing.T) {
+		repo := newMemoryRepo(t, domain.Enrollment{ID: "11", UserID: "1", CourseID: "2", Status: "S"})
+		service := enrollment.NewService(l, testUsers(), testCourses(), repo)
+
+		_, err := service.GetCertificate(ctx, "11")
+		assert.Equal(t, enrollment.ErrCertificateNotFound{EnrollmentID: "11"}, err)
 	})
 
+	t.Run("should not verify an unknown code", func(t *testing.T) {
+		service := enrollment.NewService(l, nil, nil, newMemoryRepo(t))
+
+		_, err := service.VerifyCertificate(ctx, "7KQ2-M9XD-4HRT-0CWV")
+		assert.Equal(t, enrollment.ErrCertificateNotFound{Code: "7KQ2-M9XD-4HRT-0CWV"}, err)
+	})
+}
+
+// TestService_ReadAfterWrite reads from a replica that never gets the writes,
+// the reads that follow a write must still see it.
+func TestService_ReadAfterWrite(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	newLaggingRepo := func(t *testing.T) enrollment.Repository {
+		return enrollment.NewRepo(newSQLiteDB(t), l,
+			enrollment.WithReplica(enrollment.NewReplica(newSQLiteDB(t), time.Minute)))
+	}
+
+	t.Run("should not enroll the user twice", func(t *testing.T) {
+		service := enrollment.NewService(l, testUsers(), testCourses(), newLaggingRepo(t))
+
+		_, err := service.Create(ctx, testUserID, testCourseID)
+		require.NoError(t, err)
+
+		_, err = service.Create(ctx, testUserID, testCourseID)
+		assert.ErrorIs(t, err, enrollment.ErrAlreadyEnrolled)
+	})
+
+	t.Run("should not enroll the user twice when the check races", func(t *testing.T) {
+		service := enrollment.NewService(l, testUsers(), testCourses(), racingRepo{newLaggingRepo(t)})
+
+		_, err := service.Create(ctx, testUserID, testCourseID)
+		require.NoError(t, err)
+
+		_, err = service.Create(ctx, testUserID, testCourseID)
+		assert.ErrorIs(t, err, enrollment.ErrAlreadyEnrolled)
+	})
+
+	t.Run("should complete the enrollment and issue its certificate", func(t *testing.T) {
+		service := enrollment.NewService(l, testUsers(), testCourses(), newLaggingRepo(t))
+
+		e, err := service.Create(ctx, testUserID, testCourseID)
+		require.NoError(t, err)
+
+		p, err := service.RecordProgress(ctx, e.ID, testCourseID, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 100, p.Percent)
+		assert.Equal(t, enrollment.StatusCompleted, p.Status)
+
+		c, err := service.GetCertificate(ctx, e.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Nahuel Costamagna", c.StudentName)
+	})
+}
+
+// racingRepo counts no enrollments, like a concurrent request that checks
+// before the other one inserts.
+type racingRepo struct {
+	enrollment.Repository
+}
+
+func (racingRepo) Count(context.Context, enrollment.Filters) (int, error) {
+	return 0, nil
+}
+
+func testUsers() *userSdk.UserSdkMock {
+	return &userSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+		return &domain.User{ID: id, FirstName: "Nahuel", LastName: "Costamagna"}, nil
+	}}
+}
+
+func testCourses() *courseSdk.CourseSdkMock {
+	return &courseSdk.CourseSdkMock{GetMock: func(id string) (*domain.Course, error) {
+		return &domain.Course{ID: id, Name: "Go"}, nil
+	}}
 }
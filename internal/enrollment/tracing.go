@@ -0,0 +1,54 @@
+package enrollment
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+
+// tracer is resolved on every call so it always follows the global provider.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// TracingMiddleware starts a span around a controller.
+func TracingMiddleware(name string) func(Controller) Controller {
+	return func(next Controller) Controller {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, span := tracer().Start(ctx, name)
+			defer span.End()
+
+			resp, err := next(ctx, request)
+			span.SetAttributes(attribute.Int("enrollment.status_code", statusCode(resp, err)))
+			endSpan(span, err)
+			return resp, err
+		}
+	}
+}
+
+// TraceEndpoints wraps every controller with the TracingMiddleware.
+func TraceEndpoints(e Endpoints) Endpoints {
+	return Endpoints{
+		Create:              TracingMiddleware("endpoint.Create")(e.Create),
+		Get:                 TracingMiddleware("endpoint.Get")(e.Get),
+		GetAll:              TracingMiddleware("endpoint.GetAll")(e.GetAll),
+		Update:              TracingMiddleware("endpoint.Update")(e.Update),
+		RecordProgress:      TracingMiddleware("endpoint.RecordProgress")(e.RecordProgress),
+		GetProgress:         TracingMiddleware("endpoint.GetProgress")(e.GetProgress),
+		GetCertificate:      TracingMiddleware("endpoint.GetCertificate")(e.GetCertificate),
+		DownloadCertificate: TracingMiddleware("endpoint.DownloadCertificate")(e.DownloadCertificate),
+		VerifyCertificate:   TracingMiddleware("endpoint.VerifyCertificate")(e.VerifyCertificate),
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,168 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	mockCourseSdk "github.com/ncostamagna/go_course_sdk/course/mock"
+	mockUserSdk "github.com/ncostamagna/go_course_sdk/user/mock"
+)
+
+func newTestTracer(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		_ = tp.Shutdown(context.Background())
+	})
+
+	return exporter
+}
+
+func spansByName(spans tracetest.SpanStubs) map[string]tracetest.SpanStub {
+	m := make(map[string]tracetest.SpanStub, len(spans))
+	for _, s := range spans {
+		m[s.Name] = s
+	}
+	return m
+}
+
+func TestTracing(t *testing.T) {
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("should trace the endpoint, the service and the sdk calls", func(t *testing.T) {
+		exporter := newTestTracer(t)
+
+		repo := &mockRepository{
+			CountMock: func(ctx context.Context, filters enrollment.Filters) (int, error) {
+				return 0, nil
+			},
+			CreateMock: func(ctx context.Context, enroll *domain.Enrollment) error {
+				return nil
+			},
+		}
+		userSdk := &mockUserSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, nil
+			},
+		}
+		courseSdk := &mockCourseSdk.CourseSdkMock{
+			GetMock: func(id string) (*domain.Course, error) {
+				return nil, nil
+			},
+		}
+		svc := enrollment.NewService(l, userSdk, courseSdk, repo)
+		endpoints := enrollment.TraceEndpoints(enrollment.MakeEndpoints(svc, enrollment.Config{}))
+
+		_, err := endpoints.Create(context.Background(), enrollment.CreateReq{UserID: testUserID, CourseID: testCourseID})
+		require.NoError(t, err)
+
+		spans := spansByName(exporter.GetSpans())
+		require.Len(t, spans, 4)
+
+		endpoint := spans["endpoint.Create"]
+		service := spans["service.Create"]
+		assert.Equal(t, endpoint.SpanContext.SpanID(), service.Parent.SpanID())
+		assert.Equal(t, service.SpanContext.SpanID(), spans["userTrans.Get"].Parent.SpanID())
+		assert.Equal(t, service.SpanContext.SpanID(), spans["courseTrans.Get"].Parent.SpanID())
+		assert.Equal(t, endpoint.SpanContext.TraceID(), spans["courseTrans.Get"].SpanContext.TraceID())
+	})
+
+	t.Run("should pass the span of the sdk call to the transports that take a context", func(t *testing.T) {
+		exporter := newTestTracer(t)
+
+		users := &contextUserTrans{}
+		courses := &contextCourseTrans{}
+		svc := enrollment.NewService(l,
+			enrollment.NewInstrumentingUserTransport(users, discard.NewHistogram(), discard.NewCounter()),
+			courses, newMemoryRepo(t))
+
+		_, err := svc.Create(context.Background(), testUserID, testCourseID)
+		require.NoError(t, err)
+
+		spans := spansByName(exporter.GetSpans())
+		assert.Equal(t, spans["userTrans.Get"].SpanContext.SpanID(), trace.SpanContextFromContext(users.ctx).SpanID())
+		assert.Equal(t, spans["courseTrans.Get"].SpanContext.SpanID(), trace.SpanContextFromContext(courses.ctx).SpanID())
+		assert.Equal(t, trace.SpanKindClient, spans["userTrans.Get"].SpanKind)
+	})
+
+	t.Run("should record the error of a failed sdk call", func(t *testing.T) {
+		exporter := newTestTracer(t)
+
+		userSdk := &mockUserSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, errors.New("unexpected error")
+			},
+		}
+		svc := enrollment.NewService(l, userSdk, nil, nil)
+
+		_, err := svc.Create(context.Background(), "1", "4")
+		require.Error(t, err)
+
+		spans := spansByName(exporter.GetSpans())
+		require.Len(t, spans, 2)
+		assert.Equal(t, codes.Error, spans["userTrans.Get"].Status.Code)
+		assert.Equal(t, codes.Error, spans["service.Create"].Status.Code)
+	})
+
+	t.Run("should record the error returned by the endpoint", func(t *testing.T) {
+		exporter := newTestTracer(t)
+
+		c := enrollment.TracingMiddleware("endpoint.Update")(func(ctx context.Context, request interface{}) (interface{}, error) {
+			return nil, response.NotFound("not found")
+		})
+		_, err := c(context.Background(), nil)
+		require.Error(t, err)
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+	})
+}
+
+// contextUserTrans and contextCourseTrans keep the context of the last call.
+type contextUserTrans struct {
+	ctx context.Context
+}
+
+func (t *contextUserTrans) Get(id string) (*domain.User, error) {
+	return t.GetContext(context.Background(), id)
+}
+
+func (t *contextUserTrans) GetContext(ctx context.Context, id string) (*domain.User, error) {
+	t.ctx = ctx
+	return &domain.User{ID: id}, nil
+}
+
+type contextCourseTrans struct {
+	ctx context.Context
+}
+
+func (t *contextCourseTrans) Get(id string) (*domain.Course, error) {
+	return t.GetContext(context.Background(), id)
+}
+
+func (t *contextCourseTrans) GetContext(ctx context.Context, id string) (*domain.Course, error) {
+	t.ctx = ctx
+	return &domain.Course{ID: id}, nil
+}
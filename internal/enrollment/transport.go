@@ -0,0 +1,41 @@
+package enrollment
+
+import (
+	"context"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+
+	courseSdk "github.com/ncostamagna/go_course_sdk/course"
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
+)
+
+type (
+	// UserTransport is a user transport that takes the context of the call,
+	// so the trace context reaches the user service.
+	UserTransport interface {
+		userSdk.Transport
+		GetContext(ctx context.Context, id string) (*domain.User, error)
+	}
+
+	// CourseTransport is the same as UserTransport for the course service.
+	CourseTransport interface {
+		courseSdk.Transport
+		GetContext(ctx context.Context, id string) (*domain.Course, error)
+	}
+)
+
+// getUserContext calls GetContext when the transport has it, the SDK
+// transports only have Get.
+func getUserContext(ctx context.Context, t userSdk.Transport, id string) (*domain.User, error) {
+	if t, ok := t.(UserTransport); ok {
+		return t.GetContext(ctx, id)
+	}
+	return t.Get(id)
+}
+
+func getCourseContext(ctx context.Context, t courseSdk.Transport, id string) (*domain.Course, error) {
+	if t, ok := t.(CourseTransport); ok {
+		return t.GetContext(ctx, id)
+	}
+	return t.Get(id)
+}
@@ -0,0 +1,228 @@
+package enrollment
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of an enrollment.
+const (
+	StatusPending  = "P"
+	StatusActive   = "A"
+	StatusStudying = "S"
+	StatusInactive = "I"
+	// StatusCompleted is set by the service when the progress reaches 100.
+	StatusCompleted = "C"
+)
+
+// maxLimit caps the page size of the enrollment list.
+const maxLimit = 100
+
+type (
+	// FieldError is an invalid field of a request, named as the client sends it.
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+
+	// FieldErrors lists every invalid field of a request.
+	FieldErrors []FieldError
+
+	// rule returns why the value is invalid, or an empty string when it's
+	// valid. A rule other than required skips an empty value.
+	rule func(v interface{}) string
+
+	field[T any] struct {
+		name  string
+		value func(T) interface{}
+		rules []rule
+	}
+
+	// rules declares the fields of a request and how they're validated.
+	rules[T any] []field[T]
+)
+
+var createRules = rules[CreateReq]{
+	{"user_id", func(r CreateReq) interface{} { return r.UserID }, []rule{required, isUUID}},
+	{"course_id", func(r CreateReq) interface{} { return r.CourseID }, []rule{required, isUUID}},
+}
+
+var getRules = rules[GetReq]{
+	{"id", func(r GetReq) interface{} { return r.ID }, []rule{required, isUUID}},
+	{"expand", func(r GetReq) interface{} { return r.Expand }, []rule{parses(ParseExpand)}},
+}
+
+var getAllRules = rules[GetAllReq]{
+	{"user_id", func(r GetAllReq) interface{} { return r.UserID }, []rule{isUUID}},
+	{"course_id", func(r GetAllReq) interface{} { return r.CourseID }, []rule{isUUID}},
+	{"limit", func(r GetAllReq) interface{} { return r.Limit }, []rule{between(0, maxLimit)}},
+	{"page", func(r GetAllReq) interface{} { return r.Page }, []rule{atLeast(0)}},
+	{"expand", func(r GetAllReq) interface{} { return r.Expand }, []rule{parses(ParseExpand)}},
+	{"fields", func(r GetAllReq) interface{} { return r.Fields }, []rule{parses(ParseFields)}},
+}
+
+var updateRules = rules[UpdateReq]{
+	{"id", func(r UpdateReq) interface{} { return r.ID }, []rule{required, isUUID}},
+	{"status", func(r UpdateReq) interface{} { return r.Status }, []rule{notEmpty, oneOf(StatusPending, StatusActive, StatusStudying, StatusInactive, StatusCompleted)}},
+}
+
+var recordProgressRules = rules[RecordProgressReq]{
+	{"id", func(r RecordProgressReq) interface{} { return r.ID }, []rule{required, isUUID}},
+	{"lesson_id", func(r RecordProgressReq) interface{} { return r.LessonID }, []rule{required, isUUID}},
+	{"total_lessons", func(r RecordProgressReq) interface{} { return r.TotalLessons }, []rule{atLeast(1)}},
+}
+
+var getProgressRules = rules[GetProgressReq]{
+	{"id", func(r GetProgressReq) interface{} { return r.ID }, []rule{required, isUUID}},
+}
+
+var getCertificateRules = rules[GetCertificateReq]{
+	{"id", func(r GetCertificateReq) interface{} { return r.ID }, []rule{required, isUUID}},
+}
+
+var verifyCertificateRules = rules[VerifyCertificateReq]{
+	{"code", func(r VerifyCertificateReq) interface{} { return NormalizeCertificateCode(r.Code) }, []rule{required, isCertificateCode}},
+}
+
+var streamRules = rules[StreamReq]{
+	{"user_id", func(r StreamReq) interface{} { return r.UserID }, []rule{isUUID}},
+	{"course_id", func(r StreamReq) interface{} { return r.CourseID }, []rule{isUUID}},
+}
+
+// Validate returns the invalid fields of a request of the endpoints, nil when
+// it's valid.
+func Validate(request interface{}) FieldErrors {
+	switch req := request.(type) {
+	case CreateReq:
+		return createRules.validate(req)
+	case GetReq:
+		return getRules.validate(req)
+	case GetAllReq:
+		return getAllRules.validate(req)
+	case UpdateReq:
+		return updateRules.validate(req)
+	case RecordProgressReq:
+		return recordProgressRules.validate(req)
+	case GetProgressReq:
+		return getProgressRules.validate(req)
+	case GetCertificateReq:
+		return getCertificateRules.validate(req)
+	case VerifyCertificateReq:
+		return verifyCertificateRules.validate(req)
+	case StreamReq:
+		return streamRules.validate(req)
+	}
+	return nil
+}
+
+// validate checks every field, stopping at the first rule a field breaks.
+func (rs rules[T]) validate(req T) FieldErrors {
+	var errs FieldErrors
+	for _, f := range rs {
+		v := f.value(req)
+		for _, r := range f.rules {
+			if msg := r(v); msg != "" {
+				errs = append(errs, FieldError{Field: f.name, Message: msg})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// text returns the value of a string field, false when it's missing.
+func text(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case *string:
+		if v != nil {
+			return *v, true
+		}
+	}
+	return "", false
+}
+
+func required(v interface{}) string {
+	if s, ok := text(v); !ok || s == "" {
+		return "is required"
+	}
+	return ""
+}
+
+// notEmpty rejects an empty value of a field that can be left out.
+func notEmpty(v interface{}) string {
+	if s, ok := text(v); ok && s == "" {
+		return "can't be empty"
+	}
+	return ""
+}
+
+func isUUID(v interface{}) string {
+	if s, _ := text(v); s != "" && uuid.Validate(s) != nil {
+		return "must be a UUID"
+	}
+	return ""
+}
+
+func isCertificateCode(v interface{}) string {
+	if s, _ := text(v); s != "" && !codeRe.MatchString(s) {
+		return "must be a certificate code like 7KQ2-M9XD-4HRT-0CWV"
+	}
+	return ""
+}
+
+func oneOf(values ...string) rule {
+	return func(v interface{}) string {
+		s, _ := text(v)
+		if s == "" {
+			return ""
+		}
+		for _, value := range values {
+			if s == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be %s or %s", strings.Join(values[:len(values)-1], ", "), values[len(values)-1])
+	}
+}
+
+func between(min, max int) rule {
+	return func(v interface{}) string {
+		if n, _ := v.(int); n < min || n > max {
+			return fmt.Sprintf("must be between %d and %d", min, max)
+		}
+		return ""
+	}
+}
+
+func atLeast(min int) rule {
+	return func(v interface{}) string {
+		if n, _ := v.(int); n < min {
+			return fmt.Sprintf("must be at least %d", min)
+		}
+		return ""
+	}
+}
+
+// parses adapts the parser of a list like expand or fields, its error says
+// which item is invalid.
+func parses[T any](parse func(string) (T, error)) rule {
+	return func(v interface{}) string {
+		s, _ := text(v)
+		if _, err := parse(s); err != nil {
+			return err.Error()
+		}
+		return ""
+	}
+}
@@ -0,0 +1,91 @@
+package enrollment_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	empty, active := "", "A"
+
+	obj := []struct {
+		tag     string
+		request interface{}
+		want    enrollment.FieldErrors
+	}{
+		{tag: "should accept a valid create request", request: enrollment.CreateReq{UserID: testUserID, CourseID: testCourseID}},
+		{
+			tag:     "should list every invalid field of a create request",
+			request: enrollment.CreateReq{UserID: "1"},
+			want: enrollment.FieldErrors{
+				{Field: "user_id", Message: "must be a UUID"},
+				{Field: "course_id", Message: "is required"},
+			},
+		},
+		{tag: "should accept an update without status", request: enrollment.UpdateReq{ID: testEnrollmentID}},
+		{tag: "should accept an allowed status", request: enrollment.UpdateReq{ID: testEnrollmentID, Status: &active}},
+		{
+			tag:     "should reject an empty status",
+			request: enrollment.UpdateReq{ID: testEnrollmentID, Status: &empty},
+			want:    enrollment.FieldErrors{{Field: "status", Message: "can't be empty"}},
+		},
+		{
+			tag:     "should reject an id that isn't a UUID",
+			request: enrollment.GetReq{ID: "20"},
+			want:    enrollment.FieldErrors{{Field: "id", Message: "must be a UUID"}},
+		},
+		{tag: "should accept a list without filters", request: enrollment.GetAllReq{}},
+		{tag: "should accept any event id of a stream", request: enrollment.StreamReq{LastEventID: "42"}},
+		{
+			tag:     "should reject the invalid filters of a stream",
+			request: enrollment.StreamReq{UserID: "u-1", CourseID: testEnrollmentID},
+			want:    enrollment.FieldErrors{{Field: "user_id", Message: "must be a UUID"}},
+		},
+		{tag: "should accept a certificate code in lowercase", request: enrollment.VerifyCertificateReq{Code: "7kq2-m9xd-4hrt-0cwv"}},
+		{
+			tag:     "should reject a malformed certificate code",
+			request: enrollment.VerifyCertificateReq{Code: "7KQ2-M9XD-4HRT"},
+			want:    enrollment.FieldErrors{{Field: "code", Message: "must be a certificate code like 7KQ2-M9XD-4HRT-0CWV"}},
+		},
+		{
+			tag:     "should list every invalid field of a progress event",
+			request: enrollment.RecordProgressReq{ID: testEnrollmentID, LessonID: "l-1"},
+			want: enrollment.FieldErrors{
+				{Field: "lesson_id", Message: "must be a UUID"},
+				{Field: "total_lessons", Message: "must be at least 1"},
+			},
+		},
+		{
+			tag:     "should list every invalid field of a list request",
+			request: enrollment.GetAllReq{CourseID: "c-1", Limit: 101, Page: -1, Expand: "teacher", Fields: "password"},
+			want: enrollment.FieldErrors{
+				{Field: "course_id", Message: "must be a UUID"},
+				{Field: "limit", Message: "must be between 0 and 100"},
+				{Field: "page", Message: "must be at least 0"},
+				{Field: "expand", Message: "invalid expand 'teacher', it must be user or course"},
+				{Field: "fields", Message: "invalid field 'password', it must be id, user_id, course_id or status"},
+			},
+		},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			assert.Equal(t, tt.want, enrollment.Validate(tt.request))
+		})
+	}
+
+	t.Run("should return bad request with the invalid fields", func(t *testing.T) {
+		endpoint := enrollment.MakeEndpoints(nil, enrollment.Config{LimPageDef: "10"})
+		_, err := endpoint.GetAll(context.Background(), enrollment.GetAllReq{UserID: "11", Limit: 500})
+
+		resp := err.(*enrollment.ErrorResponse)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+		assert.Equal(t, enrollment.CodeInvalidRequest, resp.Code)
+		assert.Equal(t, "user_id: must be a UUID; limit: must be between 0 and 100", resp.Message)
+		assert.Len(t, resp.Fields, 2)
+	})
+}
@@ -1,42 +1,134 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
-	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/glebarez/sqlite"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/config"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/logger"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func DBConnection() (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local",
-		os.Getenv("DATABASE_USER"),
-		os.Getenv("DATABASE_PASSWORD"),
-		os.Getenv("DATABASE_HOST"),
-		os.Getenv("DATABASE_PORT"),
-		os.Getenv("DATABASE_NAME"))
+func DBConnection(cfg config.Database) (*gorm.DB, error) {
+	dialector, err := Dialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := open(dialector, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Migrate {
+		if _, err := Migrate(context.Background(), db, "up", 0); err != nil {
+			return nil, err
+		}
+	}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	return db, err
+}
+
+// ReplicaConnection connects to the read replica, it returns nil when no
+// replica DSN is configured. The migrations only run on the primary.
+func ReplicaConnection(cfg config.Database) (*gorm.DB, error) {
+	if cfg.ReplicaDSN == "" {
+		return nil, nil
+	}
 
+	dialector, err := dialectorFromDSN(cfg.Driver, cfg.ReplicaDSN)
 	if err != nil {
 		return nil, err
 	}
 
-	if os.Getenv("DATABASE_DEBUG") == "true" {
+	return open(dialector, cfg)
+}
+
+// open connects with the pool settings of the configuration.
+func open(dialector gorm.Dialector, cfg config.Database) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Use(gormTracing{}); err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	if cfg.Debug {
 		db = db.Debug()
 	}
 
-	if os.Getenv("DATABASE_MIGRATE") == "true" {
-		if err := db.AutoMigrate(&domain.Enrollment{}); err != nil {
-			return nil, err
+	return db, nil
+}
+
+// Dialector builds the GORM dialector of the configured driver with its DSN.
+func Dialector(cfg config.Database) (gorm.Dialector, error) {
+	var dsn string
+	switch cfg.Driver {
+	case "", "mysql":
+		port := cfg.Port
+		if port == 0 {
+			port = 3306
+		}
+		dsn = fmt.Sprintf("%s:%s@(%s:%d)/%s?charset=utf8&parseTime=True&loc=Local",
+			cfg.User,
+			cfg.Password,
+			cfg.Host,
+			port,
+			cfg.Name)
+	case "postgres":
+		port := cfg.Port
+		if port == 0 {
+			port = 5432
 		}
+		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s TimeZone=UTC",
+			cfg.Host,
+			port,
+			cfg.User,
+			cfg.Password,
+			cfg.Name,
+			cfg.SSLMode)
+	case "sqlite":
+		dsn = cfg.Name
 	}
+	return dialectorFromDSN(cfg.Driver, dsn)
+}
 
-	return db, err
+func dialectorFromDSN(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "", "mysql":
+		return mysql.Open(dsn), nil
+	case "postgres":
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		return sqlite.Open(dsn), nil
+	}
+	return nil, fmt.Errorf("invalid database driver '%s'", driver)
+}
+
+// CloseDB closes the connection pool of the database.
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
 
-func InitLogger() *log.Logger {
-	return log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
+func InitLogger(cfg config.Log) (*slog.Logger, error) {
+	return logger.New(os.Stdout, cfg.Level)
 }
@@ -0,0 +1,92 @@
+package bootstrap_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/bootstrap"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialector(t *testing.T) {
+
+	obj := []struct {
+		driver string
+		want   string
+	}{
+		{driver: "mysql", want: "mysql"},
+		{driver: "postgres", want: "postgres"},
+		{driver: "sqlite", want: "sqlite"},
+	}
+
+	for _, tt := range obj {
+		t.Run("should build the "+tt.driver+" dialector", func(t *testing.T) {
+			d, err := bootstrap.Dialector(config.Database{Driver: tt.driver, Name: "enrollments"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, d.Name())
+		})
+	}
+
+	t.Run("should return an error with an unknown driver", func(t *testing.T) {
+		_, err := bootstrap.Dialector(config.Database{Driver: "oracle"})
+		assert.EqualError(t, err, "invalid database driver 'oracle'")
+	})
+}
+
+func TestDBConnectionSQLite(t *testing.T) {
+
+	db, err := bootstrap.DBConnection(config.Database{
+		Driver:       "sqlite",
+		Name:         filepath.Join(t.TempDir(), "enrollments.db"),
+		Migrate:      true,
+		MaxOpenConns: 4,
+		MaxIdleConns: 2,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bootstrap.CloseDB(db) })
+
+	t.Run("should configure the connection pool", func(t *testing.T) {
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		assert.Equal(t, 4, sqlDB.Stats().MaxOpenConnections)
+	})
+
+	t.Run("should apply the migrations", func(t *testing.T) {
+		status, err := bootstrap.Migrate(context.Background(), db, "status", 0)
+		require.NoError(t, err)
+		for _, s := range status {
+			assert.NotNil(t, s.AppliedAt, "migration %d_%s is pending", s.Version, s.Name)
+		}
+
+		for _, idx := range []string{"idx_enrollments_user_id", "idx_enrollments_course_id", "idx_enrollments_status", "idx_enrollments_user_course"} {
+			assert.True(t, db.Migrator().HasIndex(&domain.Enrollment{}, idx), "missing index %s", idx)
+		}
+		for _, table := range []string{"enrollment_progress", "enrollment_lessons", "certificates"} {
+			assert.True(t, db.Migrator().HasTable(table), "missing table %s", table)
+		}
+	})
+
+	t.Run("should store enrollments", func(t *testing.T) {
+		require.NoError(t, db.Create(&domain.Enrollment{UserID: "1", CourseID: "4", Status: "P"}).Error)
+
+		var count int64
+		require.NoError(t, db.Model(&domain.Enrollment{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("should roll back the last migrations", func(t *testing.T) {
+		status, err := bootstrap.Migrate(context.Background(), db, "down", 2)
+		require.NoError(t, err)
+		assert.Nil(t, status[len(status)-1].AppliedAt)
+		assert.Nil(t, status[len(status)-2].AppliedAt)
+		assert.False(t, db.Migrator().HasIndex(&domain.Enrollment{}, "idx_enrollments_user_course"))
+
+		status, err = bootstrap.Migrate(context.Background(), db, "up", 0)
+		require.NoError(t, err)
+		assert.NotNil(t, status[len(status)-1].AppliedAt)
+	})
+}
@@ -0,0 +1,60 @@
+package bootstrap
+
+import (
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// InitMetrics registers the enrollment metrics in the default Prometheus registry.
+func InitMetrics() enrollment.Metrics {
+	const namespace = "gocourse"
+
+	return enrollment.Metrics{
+		RequestCount: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "enrollment",
+			Name:      "requests_total",
+			Help:      "Number of requests received.",
+		}, []string{"method", "code"}),
+		RequestLatency: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "enrollment",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests in seconds.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		RepoLatency: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "enrollment_repository",
+			Name:      "query_duration_seconds",
+			Help:      "Duration of repository calls in seconds.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, []string{"method"}),
+		RepoErrors: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "enrollment_repository",
+			Name:      "errors_total",
+			Help:      "Number of failed repository calls.",
+		}, []string{"method"}),
+		SlowQueries: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "enrollment_repository",
+			Name:      "slow_queries_total",
+			Help:      "Number of repository calls slower than the slow query threshold.",
+		}, []string{"method"}),
+		SdkLatency: kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "enrollment_sdk",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of user and course service calls in seconds.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, []string{"method"}),
+		SdkErrors: kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "enrollment_sdk",
+			Name:      "errors_total",
+			Help:      "Number of failed user and course service calls.",
+		}, []string{"method"}),
+	}
+}
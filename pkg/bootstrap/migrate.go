@@ -0,0 +1,38 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ncostamagna/gocourse_enrollment/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// Migrate runs the up, down or status command of the versioned migrations and
+// returns the status of every migration. steps is only used by down.
+func Migrate(ctx context.Context, db *gorm.DB, command string, steps int) ([]migrate.Status, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.New(sqlDB, db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	switch command {
+	case "up":
+		_, err = m.Up(ctx)
+	case "down":
+		_, err = m.Down(ctx, steps)
+	case "status":
+	default:
+		return nil, fmt.Errorf("unknown migrate command '%s', use up, down or status", command)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Status(ctx)
+}
@@ -0,0 +1,429 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// Fields are filled from their default tag, then the YAML file (CONFIG_FILE) and
+// finally the environment, where the values of the .env file are loaded too.
+// A field tagged as secret can also be read from the file named in <ENV>_FILE.
+type (
+	Config struct {
+		Port                  string     `yaml:"port" env:"PORT" default:"8080"`
+		GRPCPort              string     `yaml:"grpc_port" env:"GRPC_PORT" default:"9090"`
+		PaginatorLimitDefault int        `yaml:"paginator_limit_default" env:"PAGINATOR_LIMIT_DEFAULT" default:"10"`
+		Database              Database   `yaml:"database"`
+		API                   API        `yaml:"api"`
+		Log                   Log        `yaml:"log"`
+		Tracing               Tracing    `yaml:"tracing"`
+		Health                Health     `yaml:"health"`
+		Shutdown              Shutdown   `yaml:"shutdown"`
+		RateLimit             RateLimit  `yaml:"rate_limit"`
+		Events                Events     `yaml:"events"`
+		Versioning            Versioning `yaml:"versioning"`
+	}
+
+	Database struct {
+		// Driver is mysql, postgres, sqlite or memory. For sqlite Name is the
+		// database file, memory keeps the enrollments in the process and needs
+		// nothing else.
+		Driver   string `yaml:"driver" env:"DATABASE_DRIVER" default:"mysql"`
+		User     string `yaml:"user" env:"DATABASE_USER"`
+		Password string `yaml:"password" env:"DATABASE_PASSWORD" secret:"true"`
+		Host     string `yaml:"host" env:"DATABASE_HOST" default:"127.0.0.1"`
+		// Port 0 means the default port of the driver.
+		Port    int    `yaml:"port" env:"DATABASE_PORT"`
+		Name    string `yaml:"name" env:"DATABASE_NAME"`
+		SSLMode string `yaml:"ssl_mode" env:"DATABASE_SSL_MODE" default:"disable"`
+		Debug   bool   `yaml:"debug" env:"DATABASE_DEBUG"`
+		Migrate bool   `yaml:"migrate" env:"DATABASE_MIGRATE"`
+		// ReplicaDSN is the DSN of a read replica in the format of the driver,
+		// list and count queries go to it when it's set.
+		ReplicaDSN string `yaml:"replica_dsn" env:"DATABASE_REPLICA_DSN" secret:"true"`
+		// ReplicaRetry is how long the reads stay on the primary after the
+		// replica fails.
+		ReplicaRetry time.Duration `yaml:"replica_retry" env:"DATABASE_REPLICA_RETRY" default:"30s"`
+		// MaxOpenConns 0 means unlimited connections.
+		MaxOpenConns    int           `yaml:"max_open_conns" env:"DATABASE_MAX_OPEN_CONNS" default:"25"`
+		MaxIdleConns    int           `yaml:"max_idle_conns" env:"DATABASE_MAX_IDLE_CONNS" default:"10"`
+		ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DATABASE_CONN_MAX_LIFETIME" default:"5m"`
+		// QueryTimeout bounds every repository call, 0 disables it.
+		QueryTimeout time.Duration `yaml:"query_timeout" env:"DATABASE_QUERY_TIMEOUT" default:"5s"`
+		// SlowQueryThreshold is the duration above which a repository call is
+		// logged and counted as slow, 0 disables it.
+		SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DATABASE_SLOW_QUERY_THRESHOLD" default:"500ms"`
+	}
+
+	API struct {
+		UserURL   string `yaml:"user_url" env:"API_USER_URL"`
+		CourseURL string `yaml:"course_url" env:"API_COURSE_URL"`
+		// Timeout bounds every call to the user and course services.
+		Timeout time.Duration `yaml:"timeout" env:"API_TIMEOUT" default:"5s"`
+		// ExpandConcurrency caps the calls running at the same time to expand
+		// the users and courses of a response.
+		ExpandConcurrency int `yaml:"expand_concurrency" env:"API_EXPAND_CONCURRENCY" default:"8"`
+	}
+
+	Log struct {
+		Level string `yaml:"level" env:"LOG_LEVEL" default:"info"`
+	}
+
+	Tracing struct {
+		// Exporter is otlp, stdout or empty to disable the export.
+		Exporter string `yaml:"exporter" env:"OTEL_EXPORTER"`
+	}
+
+	Health struct {
+		CheckTimeout      time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+		CacheTTL          time.Duration `yaml:"cache_ttl" env:"HEALTH_CACHE_TTL" default:"5s"`
+		CheckDependencies bool          `yaml:"check_dependencies" env:"HEALTH_CHECK_DEPENDENCIES"`
+	}
+
+	// RateLimit allows every client Requests per Period with bursts of up to
+	// Burst requests, 0 defaults to Requests.
+	RateLimit struct {
+		Enabled       bool          `yaml:"enabled" env:"RATE_LIMIT_ENABLED" default:"true"`
+		ReadRequests  int           `yaml:"read_requests" env:"RATE_LIMIT_READ_REQUESTS" default:"300"`
+		ReadPeriod    time.Duration `yaml:"read_period" env:"RATE_LIMIT_READ_PERIOD" default:"1m"`
+		ReadBurst     int           `yaml:"read_burst" env:"RATE_LIMIT_READ_BURST"`
+		WriteRequests int           `yaml:"write_requests" env:"RATE_LIMIT_WRITE_REQUESTS" default:"30"`
+		WritePeriod   time.Duration `yaml:"write_period" env:"RATE_LIMIT_WRITE_PERIOD" default:"1m"`
+		WriteBurst    int           `yaml:"write_burst" env:"RATE_LIMIT_WRITE_BURST"`
+		// TrustedProxies lists the addresses and CIDR networks of the proxies
+		// whose X-Forwarded-For header identifies the client. The service
+		// listens on loopback, so it's only reached through a local proxy.
+		TrustedProxies string `yaml:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES" default:"127.0.0.1,::1"`
+		// Routes overrides the limit of a route by its name, only from the file.
+		Routes map[string]Limit `yaml:"routes"`
+	}
+
+	Limit struct {
+		Requests int           `yaml:"requests"`
+		Period   time.Duration `yaml:"period"`
+		Burst    int           `yaml:"burst"`
+	}
+
+	// Events configures the stream of enrollment changes. BufferSize events
+	// are kept for the clients that reconnect, and a client with more than
+	// SubscriberBuffer events pending is disconnected.
+	Events struct {
+		BufferSize       int           `yaml:"buffer_size" env:"EVENTS_BUFFER_SIZE" default:"1000"`
+		SubscriberBuffer int           `yaml:"subscriber_buffer" env:"EVENTS_SUBSCRIBER_BUFFER" default:"64"`
+		Heartbeat        time.Duration `yaml:"heartbeat" env:"EVENTS_HEARTBEAT" default:"15s"`
+	}
+
+	// Versioning deprecates versions of the HTTP API by name, legacy being
+	// the unversioned routes, only from the file.
+	Versioning struct {
+		Deprecations map[string]Deprecation `yaml:"deprecations"`
+	}
+
+	// Deprecation is sent to the clients of a deprecated version from Since
+	// on, Sunset is when the version will be removed.
+	Deprecation struct {
+		Since  time.Time `yaml:"since"`
+		Sunset time.Time `yaml:"sunset"`
+	}
+
+	Shutdown struct {
+		GracePeriod time.Duration `yaml:"grace_period" env:"SHUTDOWN_GRACE_PERIOD" default:"15s"`
+		DrainDelay  time.Duration `yaml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY" default:"0s"`
+	}
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load reads the .env file, if any, and builds the configuration.
+func Load() (Config, error) {
+	_ = godotenv.Load()
+	return LoadFrom(os.Getenv("CONFIG_FILE"), os.LookupEnv)
+}
+
+// LoadDatabase is Load for the migrate subcommand, which only connects to the
+// database, so the other sections aren't validated.
+func LoadDatabase() (Config, error) {
+	_ = godotenv.Load()
+	return LoadDatabaseFrom(os.Getenv("CONFIG_FILE"), os.LookupEnv)
+}
+
+// LoadFrom builds the configuration from the YAML file, when path isn't empty,
+// and the variables returned by lookup. Every invalid value is reported.
+func LoadFrom(path string, lookup func(string) (string, bool)) (Config, error) {
+	return load(path, lookup, Config.Validate)
+}
+
+// LoadDatabaseFrom is LoadFrom validating only the database section.
+func LoadDatabaseFrom(path string, lookup func(string) (string, bool)) (Config, error) {
+	return load(path, lookup, func(c Config) error { return c.Database.Validate() })
+}
+
+func load(path string, lookup func(string) (string, bool), validate func(Config) error) (Config, error) {
+	var cfg Config
+	v := reflect.ValueOf(&cfg).Elem()
+
+	var errs []error
+	walk(v, func(f reflect.Value, sf reflect.StructField) {
+		if def, ok := sf.Tag.Lookup("default"); ok {
+			if err := set(f, def); err != nil {
+				errs = append(errs, fmt.Errorf("default %s: %w", sf.Tag.Get("env"), err))
+			}
+		}
+	})
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(b, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing config file: %w", err)
+		}
+	}
+
+	walk(v, func(f reflect.Value, sf reflect.StructField) {
+		key := sf.Tag.Get("env")
+		if key == "" {
+			return
+		}
+
+		value, ok := lookup(key)
+		if !ok && sf.Tag.Get("secret") == "true" {
+			if file, fok := lookup(key + "_FILE"); fok && file != "" {
+				b, err := os.ReadFile(file)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s_FILE: %w", key, err))
+					return
+				}
+				value, ok = strings.TrimSpace(string(b)), true
+			}
+		}
+		if !ok {
+			return
+		}
+
+		if err := set(f, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return cfg, err
+	}
+
+	return cfg, validate(cfg)
+}
+
+func walk(v reflect.Value, fn func(reflect.Value, reflect.StructField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, sf := v.Field(i), t.Field(i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+			walk(f, fn)
+			continue
+		}
+		fn(f, sf)
+	}
+}
+
+func set(f reflect.Value, value string) error {
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration '%s'", value)
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer '%s'", value)
+		}
+		f.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean '%s'", value)
+		}
+		f.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
+
+// Validate checks every field and returns all the problems found.
+func (c Config) Validate() error {
+	var errs []error
+
+	errs = append(errs, validatePort("PORT", c.Port), validatePort("GRPC_PORT", c.GRPCPort))
+
+	if c.PaginatorLimitDefault <= 0 {
+		errs = append(errs, errors.New("PAGINATOR_LIMIT_DEFAULT must be greater than 0"))
+	}
+
+	errs = append(errs, c.Database.Validate())
+
+	errs = append(errs, validateURL("API_USER_URL", c.API.UserURL), validateURL("API_COURSE_URL", c.API.CourseURL))
+	if c.API.Timeout <= 0 {
+		errs = append(errs, errors.New("API_TIMEOUT must be greater than 0"))
+	}
+	if c.API.ExpandConcurrency <= 0 {
+		errs = append(errs, errors.New("API_EXPAND_CONCURRENCY must be greater than 0"))
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL '%s' must be debug, info, warn or error", c.Log.Level))
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("OTEL_EXPORTER '%s' must be otlp or stdout", c.Tracing.Exporter))
+	}
+
+	if c.Health.CheckTimeout <= 0 {
+		errs = append(errs, errors.New("HEALTH_CHECK_TIMEOUT must be greater than 0"))
+	}
+	if c.Health.CacheTTL < 0 {
+		errs = append(errs, errors.New("HEALTH_CACHE_TTL can't be negative"))
+	}
+	if c.Shutdown.GracePeriod <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_GRACE_PERIOD must be greater than 0"))
+	}
+	if c.Shutdown.DrainDelay < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_DRAIN_DELAY can't be negative"))
+	}
+
+	if c.RateLimit.Enabled {
+		errs = append(errs,
+			validateLimit("RATE_LIMIT_READ", Limit{c.RateLimit.ReadRequests, c.RateLimit.ReadPeriod, c.RateLimit.ReadBurst}),
+			validateLimit("RATE_LIMIT_WRITE", Limit{c.RateLimit.WriteRequests, c.RateLimit.WritePeriod, c.RateLimit.WriteBurst}))
+		for route, l := range c.RateLimit.Routes {
+			errs = append(errs, validateLimit(fmt.Sprintf("rate_limit.routes.%s", route), l))
+		}
+		if _, err := ratelimit.ParseProxies(c.RateLimit.TrustedProxies); err != nil {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_TRUSTED_PROXIES has an %w", err))
+		}
+	}
+
+	if c.Events.BufferSize < 0 {
+		errs = append(errs, errors.New("EVENTS_BUFFER_SIZE can't be negative"))
+	}
+	if c.Events.SubscriberBuffer <= 0 {
+		errs = append(errs, errors.New("EVENTS_SUBSCRIBER_BUFFER must be greater than 0"))
+	}
+	if c.Events.Heartbeat <= 0 {
+		errs = append(errs, errors.New("EVENTS_HEARTBEAT must be greater than 0"))
+	}
+
+	for version, d := range c.Versioning.Deprecations {
+		switch version {
+		case "legacy", "v1":
+		default:
+			errs = append(errs, fmt.Errorf("versioning.deprecations.%s must be legacy or v1", version))
+		}
+		if d.Since.IsZero() {
+			errs = append(errs, fmt.Errorf("versioning.deprecations.%s.since is required", version))
+		}
+		if !d.Sunset.IsZero() && !d.Sunset.After(d.Since) {
+			errs = append(errs, fmt.Errorf("versioning.deprecations.%s.sunset must be after since", version))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the database fields and returns all the problems found.
+func (d Database) Validate() error {
+	var errs []error
+
+	switch d.Driver {
+	case "mysql", "postgres":
+		if d.User == "" {
+			errs = append(errs, errors.New("DATABASE_USER is required"))
+		}
+		if d.Host == "" {
+			errs = append(errs, errors.New("DATABASE_HOST is required"))
+		}
+		if d.Port < 0 || d.Port > 65535 {
+			errs = append(errs, fmt.Errorf("DATABASE_PORT '%d' is out of range", d.Port))
+		}
+	case "sqlite", "memory":
+	default:
+		errs = append(errs, fmt.Errorf("DATABASE_DRIVER '%s' must be mysql, postgres, sqlite or memory", d.Driver))
+	}
+	if d.Name == "" && d.Driver != "memory" {
+		errs = append(errs, errors.New("DATABASE_NAME is required"))
+	}
+	if d.ReplicaDSN != "" && d.Driver == "memory" {
+		errs = append(errs, errors.New("DATABASE_REPLICA_DSN isn't supported by the memory driver"))
+	}
+	if d.ReplicaRetry <= 0 {
+		errs = append(errs, errors.New("DATABASE_REPLICA_RETRY must be greater than 0"))
+	}
+	if d.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("DATABASE_MAX_OPEN_CONNS can't be negative"))
+	}
+	if d.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("DATABASE_MAX_IDLE_CONNS can't be negative"))
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, errors.New("DATABASE_MAX_IDLE_CONNS can't be greater than DATABASE_MAX_OPEN_CONNS"))
+	}
+	if d.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("DATABASE_CONN_MAX_LIFETIME can't be negative"))
+	}
+	if d.QueryTimeout < 0 {
+		errs = append(errs, errors.New("DATABASE_QUERY_TIMEOUT can't be negative"))
+	}
+	if d.SlowQueryThreshold < 0 {
+		errs = append(errs, errors.New("DATABASE_SLOW_QUERY_THRESHOLD can't be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateLimit accepts a zero limit, which disables the rate limiting.
+func validateLimit(key string, l Limit) error {
+	if l.Requests < 0 || l.Burst < 0 || l.Period < 0 || (l.Requests > 0 && l.Period == 0) {
+		return fmt.Errorf("%s needs positive requests and period", key)
+	}
+	return nil
+}
+
+func validatePort(key, port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("%s '%s' is not a valid port", key, port)
+	}
+	return nil
+}
+
+func validateURL(key, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", key)
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s '%s' is not a valid URL", key, value)
+	}
+	return nil
+}
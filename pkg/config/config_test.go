@@ -0,0 +1,251 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/gocourse_enrollment/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func validEnv() map[string]string {
+	return map[string]string{
+		"DATABASE_USER":  "root",
+		"DATABASE_NAME":  "go_course_enrollment",
+		"API_USER_URL":   "http://localhost:8081",
+		"API_COURSE_URL": "http://localhost:8082",
+	}
+}
+
+func TestLoadFrom(t *testing.T) {
+
+	t.Run("should apply the defaults", func(t *testing.T) {
+		cfg, err := config.LoadFrom("", lookup(validEnv()))
+		require.NoError(t, err)
+
+		assert.Equal(t, "8080", cfg.Port)
+		assert.Equal(t, 10, cfg.PaginatorLimitDefault)
+		assert.Equal(t, "127.0.0.1", cfg.Database.Host)
+		assert.Equal(t, "mysql", cfg.Database.Driver)
+		assert.Equal(t, "info", cfg.Log.Level)
+		assert.Equal(t, 2*time.Second, cfg.Health.CheckTimeout)
+		assert.Equal(t, 15*time.Second, cfg.Shutdown.GracePeriod)
+		assert.Equal(t, 8, cfg.API.ExpandConcurrency)
+		assert.Equal(t, 5*time.Second, cfg.API.Timeout)
+		assert.Equal(t, "127.0.0.1,::1", cfg.RateLimit.TrustedProxies)
+		assert.Equal(t, 1000, cfg.Events.BufferSize)
+		assert.Equal(t, 15*time.Second, cfg.Events.Heartbeat)
+	})
+
+	t.Run("should override the yaml file with the environment", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+port: "8000"
+paginator_limit_default: 20
+database:
+  host: db
+  port: 3323
+health:
+  cache_ttl: 1m
+`), 0o600))
+
+		env := validEnv()
+		env["PORT"] = "8001"
+		cfg, err := config.LoadFrom(path, lookup(env))
+		require.NoError(t, err)
+
+		assert.Equal(t, "8001", cfg.Port)
+		assert.Equal(t, 20, cfg.PaginatorLimitDefault)
+		assert.Equal(t, "db", cfg.Database.Host)
+		assert.Equal(t, 3323, cfg.Database.Port)
+		assert.Equal(t, time.Minute, cfg.Health.CacheTTL)
+	})
+
+	t.Run("should read the secrets from a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "password")
+		require.NoError(t, os.WriteFile(path, []byte("s3cr3t\n"), 0o600))
+
+		env := validEnv()
+		env["DATABASE_PASSWORD_FILE"] = path
+		cfg, err := config.LoadFrom("", lookup(env))
+		require.NoError(t, err)
+
+		assert.Equal(t, "s3cr3t", cfg.Database.Password)
+	})
+
+	t.Run("should only require the database name for sqlite", func(t *testing.T) {
+		env := validEnv()
+		env["DATABASE_DRIVER"] = "sqlite"
+		env["DATABASE_USER"] = ""
+		cfg, err := config.LoadFrom("", lookup(env))
+		require.NoError(t, err)
+
+		assert.Equal(t, "sqlite", cfg.Database.Driver)
+	})
+
+	t.Run("should not require any database setting for memory", func(t *testing.T) {
+		env := validEnv()
+		env["DATABASE_DRIVER"] = "memory"
+		env["DATABASE_USER"] = ""
+		env["DATABASE_NAME"] = ""
+		cfg, err := config.LoadFrom("", lookup(env))
+		require.NoError(t, err)
+
+		assert.Equal(t, "memory", cfg.Database.Driver)
+	})
+
+	t.Run("should read the rate limit of the routes from the yaml file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+rate_limit:
+  routes:
+    enrollments.create:
+      requests: 5
+      period: 1m
+      burst: 2
+`), 0o600))
+
+		env := validEnv()
+		env["RATE_LIMIT_WRITE_REQUESTS"] = "60"
+		cfg, err := config.LoadFrom(path, lookup(env))
+		require.NoError(t, err)
+
+		assert.True(t, cfg.RateLimit.Enabled)
+		assert.Equal(t, 60, cfg.RateLimit.WriteRequests)
+		assert.Equal(t, 300, cfg.RateLimit.ReadRequests)
+		assert.Equal(t, config.Limit{Requests: 5, Period: time.Minute, Burst: 2}, cfg.RateLimit.Routes["enrollments.create"])
+	})
+
+	t.Run("should read the deprecated versions from the yaml file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+versioning:
+  deprecations:
+    legacy:
+      since: 2026-11-01
+      sunset: 2027-05-01T00:00:00Z
+`), 0o600))
+
+		cfg, err := config.LoadFrom(path, lookup(validEnv()))
+		require.NoError(t, err)
+
+		assert.Equal(t, config.Deprecation{
+			Since:  time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			Sunset: time.Date(2027, 5, 1, 0, 0, 0, 0, time.UTC),
+		}, cfg.Versioning.Deprecations["legacy"])
+	})
+
+	t.Run("should reject an invalid deprecation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+versioning:
+  deprecations:
+    v0:
+      since: 2026-11-01
+    legacy:
+      since: 2026-11-01
+      sunset: 2026-10-01
+`), 0o600))
+
+		_, err := config.LoadFrom(path, lookup(validEnv()))
+		require.Error(t, err)
+
+		assert.Contains(t, err.Error(), "versioning.deprecations.v0 must be legacy or v1")
+		assert.Contains(t, err.Error(), "versioning.deprecations.legacy.sunset must be after since")
+	})
+
+	t.Run("should report every invalid value", func(t *testing.T) {
+		env := map[string]string{
+			"PAGINATOR_LIMIT_DEFAULT": "abc",
+			"DATABASE_DEBUG":          "maybe",
+			"HEALTH_CHECK_TIMEOUT":    "soon",
+		}
+		_, err := config.LoadFrom("", lookup(env))
+		require.Error(t, err)
+
+		assert.Contains(t, err.Error(), "PAGINATOR_LIMIT_DEFAULT: invalid integer 'abc'")
+		assert.Contains(t, err.Error(), "DATABASE_DEBUG: invalid boolean 'maybe'")
+		assert.Contains(t, err.Error(), "HEALTH_CHECK_TIMEOUT: invalid duration 'soon'")
+	})
+
+	t.Run("should report every missing or out of range value", func(t *testing.T) {
+		env := map[string]string{
+			"PORT":                       "99999",
+			"PAGINATOR_LIMIT_DEFAULT":    "0",
+			"API_USER_URL":               "localhost",
+			"LOG_LEVEL":                  "verbose",
+			"DATABASE_DRIVER":            "oracle",
+			"DATABASE_REPLICA_RETRY":     "0s",
+			"DATABASE_MAX_OPEN_CONNS":    "5",
+			"DATABASE_MAX_IDLE_CONNS":    "10",
+			"DATABASE_QUERY_TIMEOUT":     "-1s",
+			"RATE_LIMIT_READ_PERIOD":     "0s",
+			"RATE_LIMIT_TRUSTED_PROXIES": "10.0.0.0/8, 10.0.0.300",
+			"API_EXPAND_CONCURRENCY":     "0",
+			"API_TIMEOUT":                "0s",
+			"EVENTS_SUBSCRIBER_BUFFER":   "0",
+		}
+		_, err := config.LoadFrom("", lookup(env))
+		require.Error(t, err)
+
+		for _, want := range []string{
+			"PORT '99999' is not a valid port",
+			"PAGINATOR_LIMIT_DEFAULT must be greater than 0",
+			"DATABASE_DRIVER 'oracle' must be mysql, postgres, sqlite or memory",
+			"DATABASE_NAME is required",
+			"API_USER_URL 'localhost' is not a valid URL",
+			"API_COURSE_URL is required",
+			"LOG_LEVEL 'verbose' must be debug, info, warn or error",
+			"DATABASE_REPLICA_RETRY must be greater than 0",
+			"DATABASE_MAX_IDLE_CONNS can't be greater than DATABASE_MAX_OPEN_CONNS",
+			"DATABASE_QUERY_TIMEOUT can't be negative",
+			"RATE_LIMIT_READ needs positive requests and period",
+			"RATE_LIMIT_TRUSTED_PROXIES has an invalid address '10.0.0.300'",
+			"API_EXPAND_CONCURRENCY must be greater than 0",
+			"API_TIMEOUT must be greater than 0",
+			"EVENTS_SUBSCRIBER_BUFFER must be greater than 0",
+		} {
+			assert.Contains(t, err.Error(), want)
+		}
+	})
+}
+
+func TestLoadDatabaseFrom(t *testing.T) {
+
+	t.Run("should not require the api urls", func(t *testing.T) {
+		env := map[string]string{
+			"DATABASE_DRIVER": "sqlite",
+			"DATABASE_NAME":   "enrollments.db",
+			"API_TIMEOUT":     "0s",
+		}
+		cfg, err := config.LoadDatabaseFrom("", lookup(env))
+		require.NoError(t, err)
+
+		assert.Equal(t, "sqlite", cfg.Database.Driver)
+		assert.Equal(t, "enrollments.db", cfg.Database.Name)
+	})
+
+	t.Run("should report the invalid database values", func(t *testing.T) {
+		env := map[string]string{
+			"DATABASE_DRIVER":        "postgres",
+			"DATABASE_REPLICA_RETRY": "0s",
+		}
+		_, err := config.LoadDatabaseFrom("", lookup(env))
+		require.Error(t, err)
+
+		assert.Contains(t, err.Error(), "DATABASE_USER is required")
+		assert.Contains(t, err.Error(), "DATABASE_NAME is required")
+		assert.Contains(t, err.Error(), "DATABASE_REPLICA_RETRY must be greater than 0")
+		assert.NotContains(t, err.Error(), "API_USER_URL")
+	})
+}
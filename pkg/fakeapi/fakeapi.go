@@ -0,0 +1,178 @@
+// Package fakeapi serves the user and course routes called by the
+// go_course_sdk HTTP clients, so the service can be tested end to end
+// without the real services.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+)
+
+// Resources served by the fake.
+const (
+	Users   = "users"
+	Courses = "courses"
+)
+
+type (
+	// Fault is injected in the matching requests. Latency is applied before
+	// answering, and a StatusCode other than 0 replaces the fixture answer.
+	Fault struct {
+		Latency    time.Duration
+		StatusCode int
+		Message    string
+	}
+
+	// Server is an httptest server with the user and course fixtures. Use
+	// Server.URL as API_USER_URL and API_COURSE_URL.
+	Server struct {
+		*httptest.Server
+
+		mu       sync.RWMutex
+		users    map[string]domain.User
+		courses  map[string]domain.Course
+		faults   map[string]Fault
+		requests map[string]int
+		headers  map[string]http.Header
+	}
+
+	envelope struct {
+		Status  int         `json:"status"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data,omitempty"`
+	}
+)
+
+// New starts the server, the caller must close it.
+func New() *Server {
+	s := &Server{
+		users:    make(map[string]domain.User),
+		courses:  make(map[string]domain.Course),
+		faults:   make(map[string]Fault),
+		requests: make(map[string]int),
+		headers:  make(map[string]http.Header),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// AddUsers seeds the user fixtures, replacing the ones with the same id.
+func (s *Server) AddUsers(users ...domain.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range users {
+		s.users[u.ID] = u
+	}
+}
+
+// AddCourses seeds the course fixtures, replacing the ones with the same id.
+func (s *Server) AddCourses(courses ...domain.Course) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range courses {
+		s.courses[c.ID] = c
+	}
+}
+
+// SetFault injects the fault in the requests of the resource. An empty id
+// matches every id, a fault for a specific id takes precedence.
+func (s *Server) SetFault(resource, id string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[key(resource, id)] = f
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = make(map[string]Fault)
+}
+
+// Requests returns the number of requests received for the resource id, or
+// for the whole resource when id is empty.
+func (s *Server) Requests(resource, id string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.requests[key(resource, id)]
+}
+
+// Header returns the headers of the last request received for the resource
+// id, or for the whole resource when id is empty. It's nil before the first
+// request.
+func (s *Server) Header(resource, id string) http.Header {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.headers[key(resource, id)]
+}
+
+func key(resource, id string) string {
+	if id == "" {
+		return resource
+	}
+	return resource + "/" + id
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	resource, id, ok := strings.Cut(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method != http.MethodGet || !ok || id == "" || strings.Contains(id, "/") ||
+		(resource != Users && resource != Courses) {
+		write(w, http.StatusNotFound, fmt.Sprintf("route '%s %s' not found", r.Method, r.URL.Path), nil)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[key(resource, "")]++
+	s.requests[key(resource, id)]++
+	s.headers[key(resource, "")] = r.Header.Clone()
+	s.headers[key(resource, id)] = r.Header.Clone()
+	fault, faulty := s.faults[key(resource, id)]
+	if !faulty {
+		fault, faulty = s.faults[key(resource, "")]
+	}
+	var data interface{}
+	if u, found := s.users[id]; found && resource == Users {
+		data = u
+	}
+	if c, found := s.courses[id]; found && resource == Courses {
+		data = c
+	}
+	s.mu.Unlock()
+
+	if faulty {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.StatusCode != 0 {
+			msg := fault.Message
+			if msg == "" {
+				msg = http.StatusText(fault.StatusCode)
+			}
+			write(w, fault.StatusCode, msg, nil)
+			return
+		}
+	}
+
+	if data == nil {
+		write(w, http.StatusNotFound, fmt.Sprintf("%s '%s' doesn't exist", strings.TrimSuffix(resource, "s"), id), nil)
+		return
+	}
+	write(w, http.StatusOK, "success", data)
+}
+
+func write(w http.ResponseWriter, status int, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(envelope{Status: status, Message: msg, Data: data})
+}
@@ -0,0 +1,87 @@
+package fakeapi_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/fakeapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	courseSdk "github.com/ncostamagna/go_course_sdk/course"
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
+)
+
+func TestServer(t *testing.T) {
+	srv := fakeapi.New()
+	defer srv.Close()
+
+	srv.AddUsers(domain.User{ID: "1", FirstName: "Nahuel", LastName: "Costamagna", Email: "nahuel@mail.com"})
+	srv.AddCourses(domain.Course{ID: "4", Name: "Go"})
+
+	users := userSdk.NewHttpClient(srv.URL, "")
+	courses := courseSdk.NewHttpClient(srv.URL, "")
+
+	t.Run("should return the fixtures", func(t *testing.T) {
+		u, err := users.Get("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Nahuel", u.FirstName)
+		assert.Equal(t, "nahuel@mail.com", u.Email)
+
+		c, err := courses.Get("4")
+		require.NoError(t, err)
+		assert.Equal(t, "Go", c.Name)
+	})
+
+	t.Run("should return not found for a missing fixture", func(t *testing.T) {
+		_, err := users.Get("2")
+		var notFound userSdk.ErrNotFound
+		require.True(t, errors.As(err, &notFound), "unexpected error: %v", err)
+		assert.Equal(t, "user '2' doesn't exist", notFound.Message)
+	})
+
+	t.Run("should inject a status code for the whole resource", func(t *testing.T) {
+		t.Cleanup(srv.ClearFaults)
+		srv.SetFault(fakeapi.Courses, "", fakeapi.Fault{StatusCode: 500, Message: "database down"})
+
+		_, err := courses.Get("4")
+		assert.EqualError(t, err, "database down")
+
+		_, err = users.Get("1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should inject a not found for an id", func(t *testing.T) {
+		t.Cleanup(srv.ClearFaults)
+		srv.SetFault(fakeapi.Users, "1", fakeapi.Fault{StatusCode: 404})
+
+		_, err := users.Get("1")
+		assert.Equal(t, userSdk.ErrNotFound{Message: "Not Found"}, err)
+	})
+
+	t.Run("should inject latency", func(t *testing.T) {
+		t.Cleanup(srv.ClearFaults)
+		srv.SetFault(fakeapi.Users, "", fakeapi.Fault{Latency: 50 * time.Millisecond})
+
+		start := time.Now()
+		_, err := users.Get("1")
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("should count the requests", func(t *testing.T) {
+		before := srv.Requests(fakeapi.Courses, "4")
+		_, _ = courses.Get("4")
+		assert.Equal(t, before+1, srv.Requests(fakeapi.Courses, "4"))
+	})
+
+	t.Run("should keep the headers of the last request", func(t *testing.T) {
+		assert.Nil(t, srv.Header(fakeapi.Users, "9"))
+
+		_, _ = users.Get("9")
+		assert.Equal(t, "application/json", srv.Header(fakeapi.Users, "9").Get("Accept"))
+		assert.Equal(t, "application/json", srv.Header(fakeapi.Users, "").Get("Accept"))
+	})
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+)
+
+// Cache-Control of the reads, the clients may keep them but must revalidate
+// them with the ETag or Last-Modified, and of everything else.
+const (
+	cacheControlRead    = "private, no-cache"
+	cacheControlNoStore = "no-store"
+)
+
+type conditionalKey struct{}
+
+// conditional holds the validators sent by the client.
+type conditional struct {
+	ifNoneMatch     string
+	ifModifiedSince string
+}
+
+// conditionalRequest keeps the validators of the request for encodeResponse,
+// only the read routes use it.
+func conditionalRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, conditionalKey{}, conditional{
+		ifNoneMatch:     r.Header.Get("If-None-Match"),
+		ifModifiedSince: r.Header.Get("If-Modified-Since"),
+	})
+}
+
+// unwrapCached returns the response and its last modification time, zero when
+// it isn't known.
+func unwrapCached(r response.Response) (response.Response, time.Time) {
+	if c, ok := r.(enrollment.CachedResponse); ok {
+		return c.Response, c.LastModified
+	}
+	return r, time.Time{}
+}
+
+// encodeCached writes a successful read with its validators, or 304 when the
+// client already has it.
+func encodeCached(w http.ResponseWriter, cond conditional, r response.Response, lastModified time.Time) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	h := w.Header()
+	h.Set("Cache-Control", cacheControlRead)
+	h.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(cond, etag, lastModified) {
+		h.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.WriteHeader(r.StatusCode())
+	_, err = w.Write(body)
+	return err
+}
+
+// notModified evaluates the validators as RFC 9110 does for a GET:
+// If-Modified-Since is ignored when If-None-Match is sent.
+func notModified(cond conditional, etag string, lastModified time.Time) bool {
+	if cond.ifNoneMatch != "" {
+		for _, tag := range strings.Split(cond.ifNoneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if cond.ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(cond.ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	// the header has a precision of seconds
+	return !lastModified.Truncate(time.Second).After(since)
+}
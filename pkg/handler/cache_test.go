@@ -0,0 +1,114 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalGet(t *testing.T) {
+
+	updatedAt := time.Date(2024, 5, 1, 10, 30, 15, 500, time.UTC)
+	status := "P"
+	h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{
+		GetAll: func(ctx context.Context, request interface{}) (interface{}, error) {
+			return enrollment.CachedResponse{
+				Response:     response.OK("success", []domain.Enrollment{{ID: "1", Status: status}}, nil),
+				LastModified: updatedAt,
+			}, nil
+		},
+		Get: func(ctx context.Context, request interface{}) (interface{}, error) {
+			return response.OK("success", domain.Enrollment{ID: "1"}, nil), nil
+		},
+		Update: func(ctx context.Context, request interface{}) (interface{}, error) {
+			return response.OK("success", nil, nil), nil
+		},
+	})
+
+	get := func(t *testing.T, path string, header map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get(t, "/enrollments", nil)
+	etag := first.Header().Get("ETag")
+
+	t.Run("should send the validators of a read", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, first.Code)
+		assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+		assert.Equal(t, "Wed, 01 May 2024 10:30:15 GMT", first.Header().Get("Last-Modified"))
+		assert.Equal(t, "private, no-cache", first.Header().Get("Cache-Control"))
+	})
+
+	t.Run("should only send the ETag when the last modification isn't known", func(t *testing.T) {
+		rec := get(t, "/enrollments/1", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("ETag"))
+		assert.Empty(t, rec.Header().Get("Last-Modified"))
+	})
+
+	obj := []struct {
+		tag    string
+		header map[string]string
+		want   int
+	}{
+		{tag: "should return not modified when the ETag matches", header: map[string]string{"If-None-Match": etag}, want: http.StatusNotModified},
+		{tag: "should return not modified when one of the ETags matches", header: map[string]string{"If-None-Match": `"other", W/` + etag}, want: http.StatusNotModified},
+		{tag: "should return not modified with any ETag", header: map[string]string{"If-None-Match": "*"}, want: http.StatusNotModified},
+		{tag: "should return the enrollments when the ETag doesn't match", header: map[string]string{"If-None-Match": `"other"`}, want: http.StatusOK},
+		{tag: "should return not modified when nothing changed since the date", header: map[string]string{"If-Modified-Since": "Wed, 01 May 2024 10:30:15 GMT"}, want: http.StatusNotModified},
+		{tag: "should return the enrollments when they changed since the date", header: map[string]string{"If-Modified-Since": "Wed, 01 May 2024 10:30:14 GMT"}, want: http.StatusOK},
+		{tag: "should ignore an invalid date", header: map[string]string{"If-Modified-Since": "yesterday"}, want: http.StatusOK},
+		{
+			tag:    "should ignore the date when an ETag is sent",
+			header: map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": "Wed, 01 May 2024 10:30:15 GMT"},
+			want:   http.StatusOK,
+		},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			rec := get(t, "/enrollments", tt.header)
+			assert.Equal(t, tt.want, rec.Code)
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			if tt.want == http.StatusNotModified {
+				assert.Empty(t, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("should change the ETag when the enrollments change", func(t *testing.T) {
+		status = "A"
+		t.Cleanup(func() { status = "P" })
+
+		rec := get(t, "/enrollments", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+	})
+
+	t.Run("should not store the writes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "/enrollments/1", strings.NewReader(`{"status":"A"}`))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.Empty(t, rec.Header().Get("ETag"))
+	})
+}
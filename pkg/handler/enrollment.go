@@ -3,9 +3,16 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
@@ -14,34 +21,155 @@ import (
 	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
 )
 
-func NewEnrollmentHTTPServer(ctx context.Context, endpoints enrollment.Endpoints) http.Handler {
+type (
+	// ServerOption configures the HTTP server built by NewEnrollmentHTTPServer.
+	ServerOption func(*serverOptions)
+
+	serverOptions struct {
+		logger       *slog.Logger
+		rateLimits   RateLimits
+		eventStream  EventStream
+		deprecations map[string]Deprecation
+	}
+)
+
+// maxBodySize caps the request bodies, an enrollment request is a few bytes.
+const maxBodySize = 64 << 10
+
+// unknownFieldPrefix starts the error of a field DisallowUnknownFields rejects.
+const unknownFieldPrefix = "json: unknown field "
+
+// Route names, used to configure the rate limit of each route.
+const (
+	RouteCreate              = "enrollments.create"
+	RouteGet                 = "enrollments.get"
+	RouteGetAll              = "enrollments.get_all"
+	RouteStream              = "enrollments.stream"
+	RouteUpdate              = "enrollments.update"
+	RouteRecordProgress      = "enrollments.record_progress"
+	RouteGetProgress         = "enrollments.get_progress"
+	RouteGetCertificate      = "enrollments.get_certificate"
+	RouteDownloadCertificate = "enrollments.download_certificate"
+	RouteVerifyCertificate   = "certificates.verify"
+)
+
+// WithLogger sets the logger of the errors that don't reach the client.
+func WithLogger(l *slog.Logger) ServerOption {
+	return func(o *serverOptions) {
+		o.logger = l
+	}
+}
+
+// WithRateLimits limits the requests of every client to the enrollment routes.
+func WithRateLimits(cfg RateLimits) ServerOption {
+	return func(o *serverOptions) {
+		o.rateLimits = cfg
+	}
+}
+
+func NewEnrollmentHTTPServer(ctx context.Context, endpoints enrollment.Endpoints, serverOpts ...ServerOption) http.Handler {
+
+	o := serverOptions{logger: slog.New(slog.DiscardHandler)}
+	for _, opt := range serverOpts {
+		opt(&o)
+	}
 
 	r := mux.NewRouter()
+	r.Use(requestIDMiddleware, tracingMiddleware, rateLimitMiddleware(o.rateLimits, o.logger))
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		encodeError(req.Context(), enrollment.NewError(http.StatusNotFound, enrollment.CodeRouteNotFound,
+			fmt.Sprintf("route '%s %s' doesn't exist", req.Method, req.URL.Path)), w)
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		encodeError(req.Context(), enrollment.NewError(http.StatusMethodNotAllowed, enrollment.CodeMethodNotAllowed,
+			fmt.Sprintf("method '%s' isn't allowed on '%s'", req.Method, req.URL.Path)), w)
+	})
 
 	opts := []httptransport.ServerOption{
 		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerErrorHandler(errorLogger{o.logger}),
 	}
+	readOpts := append([]httptransport.ServerOption{httptransport.ServerBefore(conditionalRequest)}, opts...)
 
-	r.Handle("/enrollments", httptransport.NewServer(
+	create := httptransport.NewServer(
 		endpoint.Endpoint(endpoints.Create),
 		decodeStoreEnrollment,
 		encodeResponse,
 		opts...,
-	)).Methods("POST")
-
-	r.Handle("/enrollments", httptransport.NewServer(
+	)
+	getAll := httptransport.NewServer(
 		endpoint.Endpoint(endpoints.GetAll),
 		decodeGetAllEnrollment,
 		encodeResponse,
-		opts...,
-	)).Methods("GET")
-
-	r.Handle("/enrollments/{id}", httptransport.NewServer(
+		readOpts...,
+	)
+	stream := streamEnrollments(ctx, o.eventStream, o.logger)
+	get := httptransport.NewServer(
+		endpoint.Endpoint(endpoints.Get),
+		decodeGetEnrollment,
+		encodeResponse,
+		readOpts...,
+	)
+	update := httptransport.NewServer(
 		endpoint.Endpoint(endpoints.Update),
 		decodeUpdateEnrollment,
 		encodeResponse,
 		opts...,
-	)).Methods("PATCH")
+	)
+	recordProgress := httptransport.NewServer(
+		endpoint.Endpoint(endpoints.RecordProgress),
+		decodeRecordProgress,
+		encodeResponse,
+		opts...,
+	)
+	getProgress := httptransport.NewServer(
+		endpoint.Endpoint(endpoints.GetProgress),
+		decodeGetProgress,
+		encodeResponse,
+		opts...,
+	)
+	getCertificate := httptransport.NewServer(
+		endpoint.Endpoint(endpoints.GetCertificate),
+		decodeGetCertificate,
+		encodeResponse,
+		opts...,
+	)
+	downloadCertificate := httptransport.NewServer(
+		endpoint.Endpoint(endpoints.DownloadCertificate),
+		decodeGetCertificate,
+		encodeFile,
+		opts...,
+	)
+	verifyCertificate := httptransport.NewServer(
+		endpoint.Endpoint(endpoints.VerifyCertificate),
+		decodeVerifyCertificate,
+		encodeResponse,
+		opts...,
+	)
+
+	// the routes keep their names in every version, so they share the rate
+	// limit of the route
+	v1 := func(r *mux.Router) {
+		r.Handle("/enrollments", create).Methods("POST").Name(RouteCreate)
+		r.Handle("/enrollments", getAll).Methods("GET").Name(RouteGetAll)
+		// registered before /enrollments/{id}, which would match it too
+		r.Handle("/enrollments/stream", stream).Methods("GET").Name(RouteStream)
+		r.Handle("/enrollments/{id}", get).Methods("GET").Name(RouteGet)
+		r.Handle("/enrollments/{id}", update).Methods("PATCH").Name(RouteUpdate)
+		r.Handle("/enrollments/{id}/progress", recordProgress).Methods("POST").Name(RouteRecordProgress)
+		r.Handle("/enrollments/{id}/progress", getProgress).Methods("GET").Name(RouteGetProgress)
+		r.Handle("/enrollments/{id}/certificate", getCertificate).Methods("GET").Name(RouteGetCertificate)
+		r.Handle("/enrollments/{id}/certificate/pdf", downloadCertificate).Methods("GET").Name(RouteDownloadCertificate)
+		r.Handle("/certificates/{code}/verify", verifyCertificate).Methods("GET").Name(RouteVerifyCertificate)
+	}
+
+	r.HandleFunc("/openapi.json", serveOpenAPI).Methods("GET")
+	r.HandleFunc("/docs", serveSwaggerUI).Methods("GET")
+
+	mountVersions(r, o.deprecations, []apiVersion{
+		{name: VersionV1, prefix: "/v1", routes: v1},
+		{name: VersionLegacy, successor: "/v1", routes: v1},
+	})
 
 	return r
 
@@ -49,8 +177,8 @@ func NewEnrollmentHTTPServer(ctx context.Context, endpoints enrollment.Endpoints
 
 func decodeStoreEnrollment(_ context.Context, r *http.Request) (interface{}, error) {
 	var req enrollment.CreateReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, response.BadRequest(fmt.Sprintf("invalid request format: '%v'", err.Error()))
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
 	}
 
 	return req, nil
@@ -60,24 +188,52 @@ func decodeGetAllEnrollment(_ context.Context, r *http.Request) (interface{}, er
 
 	v := r.URL.Query()
 
-	limit, _ := strconv.Atoi(v.Get("limit"))
-	page, _ := strconv.Atoi(v.Get("page"))
+	var errs enrollment.FieldErrors
+	limit := queryInt(v, "limit", &errs)
+	page := queryInt(v, "page", &errs)
 
 	req := enrollment.GetAllReq{
 		UserID:   v.Get("user_id"),
 		CourseID: v.Get("course_id"),
 		Limit:    limit,
 		Page:     page,
+		Expand:   v.Get("expand"),
+		Fields:   v.Get("fields"),
+	}
+
+	// list the other invalid fields too, the endpoint won't see the request
+	if errs != nil {
+		return nil, enrollment.InvalidFields(append(errs, enrollment.Validate(req)...))
 	}
 
 	return req, nil
 }
 
+// queryInt returns an integer of the query, 0 when it's missing.
+func queryInt(v url.Values, key string, errs *enrollment.FieldErrors) int {
+	s := v.Get(key)
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		*errs = append(*errs, enrollment.FieldError{Field: key, Message: "must be an integer"})
+	}
+	return n
+}
+
+func decodeGetEnrollment(_ context.Context, r *http.Request) (interface{}, error) {
+	return enrollment.GetReq{
+		ID:     mux.Vars(r)["id"],
+		Expand: r.URL.Query().Get("expand"),
+	}, nil
+}
+
 func decodeUpdateEnrollment(_ context.Context, r *http.Request) (interface{}, error) {
 	var req enrollment.UpdateReq
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, response.BadRequest(fmt.Sprintf("invalid request format: '%v'", err.Error()))
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
 	}
 
 	path := mux.Vars(r)
@@ -86,18 +242,137 @@ func decodeUpdateEnrollment(_ context.Context, r *http.Request) (interface{}, er
 	return req, nil
 }
 
+func decodeRecordProgress(_ context.Context, r *http.Request) (interface{}, error) {
+	var req enrollment.RecordProgressReq
+	if err := decodeJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	req.ID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func decodeGetProgress(_ context.Context, r *http.Request) (interface{}, error) {
+	return enrollment.GetProgressReq{ID: mux.Vars(r)["id"]}, nil
+}
+
+func decodeGetCertificate(_ context.Context, r *http.Request) (interface{}, error) {
+	return enrollment.GetCertificateReq{ID: mux.Vars(r)["id"]}, nil
+}
+
+func decodeVerifyCertificate(_ context.Context, r *http.Request) (interface{}, error) {
+	return enrollment.VerifyCertificateReq{Code: mux.Vars(r)["code"]}, nil
+}
+
+// decodeJSON decodes a body holding a single JSON object with only the
+// fields of v.
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return decodeError(err)
+		}
+		return enrollment.InvalidRequest("the body must hold a single JSON object")
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		return enrollment.NewError(http.StatusRequestEntityTooLarge, enrollment.CodeRequestTooLarge,
+			fmt.Sprintf("the body can't be larger than %d bytes", tooLarge.Limit))
+	case errors.Is(err, io.EOF):
+		return enrollment.InvalidRequest("the body is empty")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return enrollment.InvalidFields(enrollment.FieldErrors{
+			{Field: typeErr.Field, Message: "must be a " + jsonType(typeErr.Type)},
+		})
+	case errors.As(err, &typeErr):
+		return enrollment.InvalidRequest("the body must be a JSON object")
+	case strings.HasPrefix(err.Error(), unknownFieldPrefix):
+		// encoding/json has no error type for it
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), unknownFieldPrefix))
+		return enrollment.InvalidFields(enrollment.FieldErrors{{Field: name, Message: "isn't a known field"}})
+	}
+
+	return enrollment.InvalidRequest(fmt.Sprintf("invalid request format: '%v'", err.Error()))
+}
+
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return t.String()
+}
+
 func encodeResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
-	r := resp.(response.Response)
+	r, lastModified := unwrapCached(resp.(response.Response))
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if cond, ok := ctx.Value(conditionalKey{}).(conditional); ok && r.StatusCode() == http.StatusOK {
+		return encodeCached(w, cond, r, lastModified)
+	}
+
+	w.Header().Set("Cache-Control", cacheControlNoStore)
 	w.WriteHeader(r.StatusCode())
-	return json.NewEncoder(w).Encode(resp)
+	return json.NewEncoder(w).Encode(r)
+}
+
+// encodeFile sends a download as an attachment.
+func encodeFile(_ context.Context, w http.ResponseWriter, resp interface{}) error {
+	f := resp.(enrollment.File)
+	w.Header().Set("Content-Type", f.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.Content)))
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+	_, err := w.Write(f.Content)
+	return err
 }
 
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	resp := err.(response.Response)
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+	resp, ok := err.(response.Response)
+	if !ok {
+		resp = enrollment.NewError(http.StatusInternalServerError, enrollment.CodeInternal, "internal error")
+	}
 
 	w.WriteHeader(resp.StatusCode())
 
 	_ = json.NewEncoder(w).Encode(resp)
 }
+
+// errorLogger logs the cause of the errors that isn't sent to the client.
+type errorLogger struct {
+	logger *slog.Logger
+}
+
+func (h errorLogger) Handle(ctx context.Context, err error) {
+	var resp *enrollment.ErrorResponse
+	if !errors.As(err, &resp) {
+		h.logger.ErrorContext(ctx, "request failed", "error", err)
+		return
+	}
+	if cause := resp.Unwrap(); cause != nil {
+		h.logger.ErrorContext(ctx, "request failed", "code", resp.Code, "error", cause)
+	}
+}
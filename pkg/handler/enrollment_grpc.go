@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/pb"
+	"github.com/ncostamagna/gocourse_meta/meta"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the ErrorInfo details of the gRPC errors.
+const errorDomain = "enrollments"
+
+type grpcServer struct {
+	pb.UnimplementedEnrollmentServiceServer
+	create grpctransport.Handler
+	getAll grpctransport.Handler
+	update grpctransport.Handler
+}
+
+func NewEnrollmentGRPCServer(ctx context.Context, endpoints enrollment.Endpoints, serverOpts ...ServerOption) pb.EnrollmentServiceServer {
+
+	o := serverOptions{logger: slog.New(slog.DiscardHandler)}
+	for _, opt := range serverOpts {
+		opt(&o)
+	}
+
+	opts := []grpctransport.ServerOption{
+		grpctransport.ServerBefore(grpcRequestID),
+		grpctransport.ServerErrorHandler(errorLogger{o.logger}),
+	}
+
+	return &grpcServer{
+		create: grpctransport.NewServer(
+			endpoint.Endpoint(endpoints.Create),
+			decodeGRPCStoreEnrollment,
+			encodeGRPCStoreEnrollment,
+			opts...,
+		),
+		getAll: grpctransport.NewServer(
+			endpoint.Endpoint(endpoints.GetAll),
+			decodeGRPCGetAllEnrollment,
+			encodeGRPCGetAllEnrollment,
+			opts...,
+		),
+		update: grpctransport.NewServer(
+			endpoint.Endpoint(endpoints.Update),
+			decodeGRPCUpdateEnrollment,
+			encodeGRPCUpdateEnrollment,
+			opts...,
+		),
+	}
+}
+
+func (s *grpcServer) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateResponse, error) {
+	_, resp, err := s.create.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.CreateResponse), nil
+}
+
+func (s *grpcServer) GetAll(ctx context.Context, req *pb.GetAllRequest) (*pb.GetAllResponse, error) {
+	_, resp, err := s.getAll.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.GetAllResponse), nil
+}
+
+func (s *grpcServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateResponse, error) {
+	_, resp, err := s.update.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.(*pb.UpdateResponse), nil
+}
+
+func decodeGRPCStoreEnrollment(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.CreateRequest)
+	return enrollment.CreateReq{
+		UserID:   req.GetUserId(),
+		CourseID: req.GetCourseId(),
+	}, nil
+}
+
+func encodeGRPCStoreEnrollment(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(response.Response)
+	enroll, _ := r.GetData().(*domain.Enrollment)
+	return &pb.CreateResponse{Enrollment: toPBEnrollment(enroll)}, nil
+}
+
+func decodeGRPCGetAllEnrollment(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.GetAllRequest)
+	return enrollment.GetAllReq{
+		UserID:   req.GetUserId(),
+		CourseID: req.GetCourseId(),
+		Limit:    int(req.GetLimit()),
+		Page:     int(req.GetPage()),
+	}, nil
+}
+
+func encodeGRPCGetAllEnrollment(_ context.Context, resp interface{}) (interface{}, error) {
+	r, _ := unwrapCached(resp.(response.Response))
+	enrollments, _ := r.GetData().([]domain.Enrollment)
+
+	res := &pb.GetAllResponse{
+		Enrollments: make([]*pb.Enrollment, 0, len(enrollments)),
+	}
+	for i := range enrollments {
+		res.Enrollments = append(res.Enrollments, toPBEnrollment(&enrollments[i]))
+	}
+
+	if s, ok := r.(*response.SuccessResponse); ok {
+		res.Meta = toPBMeta(s.Meta)
+	}
+
+	return res, nil
+}
+
+func decodeGRPCUpdateEnrollment(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*pb.UpdateRequest)
+	return enrollment.UpdateReq{
+		ID:     req.GetId(),
+		Status: req.Status,
+	}, nil
+}
+
+func encodeGRPCUpdateEnrollment(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.UpdateResponse{}, nil
+}
+
+func toPBEnrollment(e *domain.Enrollment) *pb.Enrollment {
+	if e == nil {
+		return nil
+	}
+	return &pb.Enrollment{
+		Id:       e.ID,
+		UserId:   e.UserID,
+		CourseId: e.CourseID,
+		Status:   e.Status,
+	}
+}
+
+func toPBMeta(m *meta.Meta) *pb.Meta {
+	if m == nil {
+		return nil
+	}
+	return &pb.Meta{
+		Page:       int32(m.Page),
+		PerPage:    int32(m.PerPage),
+		PageCount:  int32(m.PageCount),
+		TotalCount: int32(m.TotalCount),
+	}
+}
+
+// grpcError converts the response.Response errors returned by the endpoints
+// into a gRPC status with the equivalent code. The code of the error catalog
+// is sent as the reason of an ErrorInfo detail.
+func grpcError(err error) error {
+	var resp response.Response
+	if !errors.As(err, &resp) {
+		return status.Error(codes.Unknown, err.Error())
+	}
+
+	st := status.New(grpcCode(resp.StatusCode()), resp.Error())
+	var catalog *enrollment.ErrorResponse
+	if errors.As(err, &catalog) {
+		if withCode, err := st.WithDetails(&errdetails.ErrorInfo{Reason: catalog.Code, Domain: errorDomain}); err == nil {
+			st = withCode
+		}
+	}
+	return st.Err()
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusInternalServerError:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
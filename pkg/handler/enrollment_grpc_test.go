@@ -0,0 +1,195 @@
+package handler_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/pb"
+	"github.com/ncostamagna/gocourse_meta/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newGRPCClient(t *testing.T, endpoints enrollment.Endpoints) pb.EnrollmentServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	pb.RegisterEnrollmentServiceServer(srv, handler.NewEnrollmentGRPCServer(context.Background(), endpoints))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pb.NewEnrollmentServiceClient(conn)
+}
+
+func TestGRPCCreate(t *testing.T) {
+
+	t.Run("should return the created enrollment", func(t *testing.T) {
+		client := newGRPCClient(t, enrollment.Endpoints{
+			Create: func(ctx context.Context, request interface{}) (interface{}, error) {
+				req := request.(enrollment.CreateReq)
+				assert.Equal(t, "1", req.UserID)
+				assert.Equal(t, "4", req.CourseID)
+				return response.Created("success", &domain.Enrollment{
+					ID:       "10010",
+					UserID:   req.UserID,
+					CourseID: req.CourseID,
+					Status:   "P",
+				}, nil), nil
+			},
+		})
+
+		resp, err := client.Create(context.Background(), &pb.CreateRequest{UserId: "1", CourseId: "4"})
+		require.NoError(t, err)
+		assert.Equal(t, "10010", resp.GetEnrollment().GetId())
+		assert.Equal(t, "1", resp.GetEnrollment().GetUserId())
+		assert.Equal(t, "4", resp.GetEnrollment().GetCourseId())
+		assert.Equal(t, "P", resp.GetEnrollment().GetStatus())
+	})
+
+	obj := []struct {
+		tag      string
+		err      error
+		wantCode codes.Code
+	}{
+		{tag: "should map bad request to invalid argument", err: response.BadRequest("user id is required"), wantCode: codes.InvalidArgument},
+		{tag: "should map not found to not found", err: response.NotFound("user not found"), wantCode: codes.NotFound},
+		{tag: "should map internal server error to internal", err: response.InternalServerError("unexpected error"), wantCode: codes.Internal},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			client := newGRPCClient(t, enrollment.Endpoints{
+				Create: func(ctx context.Context, request interface{}) (interface{}, error) {
+					return nil, tt.err
+				},
+			})
+
+			_, err := client.Create(context.Background(), &pb.CreateRequest{})
+			st, ok := status.FromError(err)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantCode, st.Code())
+			assert.Equal(t, tt.err.Error(), st.Message())
+		})
+	}
+}
+
+func TestGRPCErrorCode(t *testing.T) {
+
+	t.Run("should send the code of the error catalog as the reason", func(t *testing.T) {
+		client := newGRPCClient(t, enrollment.Endpoints{
+			Create: func(ctx context.Context, request interface{}) (interface{}, error) {
+				return nil, enrollment.NewError(http.StatusConflict, enrollment.CodeAlreadyEnrolled, "the user is already enrolled in the course")
+			},
+		})
+
+		_, err := client.Create(context.Background(), &pb.CreateRequest{})
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		assert.Equal(t, codes.AlreadyExists, st.Code())
+		require.Len(t, st.Details(), 1)
+		info := st.Details()[0].(*errdetails.ErrorInfo)
+		assert.Equal(t, enrollment.CodeAlreadyEnrolled, info.GetReason())
+		assert.Equal(t, "enrollments", info.GetDomain())
+	})
+}
+
+func TestGRPCGetAll(t *testing.T) {
+
+	t.Run("should return the enrollments with meta", func(t *testing.T) {
+		client := newGRPCClient(t, enrollment.Endpoints{
+			GetAll: func(ctx context.Context, request interface{}) (interface{}, error) {
+				req := request.(enrollment.GetAllReq)
+				assert.Equal(t, "1", req.UserID)
+				assert.Equal(t, 10, req.Limit)
+				assert.Equal(t, 2, req.Page)
+				m, _ := meta.New(req.Page, req.Limit, 12, "")
+				return response.OK("success", []domain.Enrollment{
+					{ID: "a", UserID: "1", CourseID: "4", Status: "P"},
+					{ID: "b", UserID: "1", CourseID: "5", Status: "A"},
+				}, m), nil
+			},
+		})
+
+		resp, err := client.GetAll(context.Background(), &pb.GetAllRequest{UserId: "1", Limit: 10, Page: 2})
+		require.NoError(t, err)
+		require.Len(t, resp.GetEnrollments(), 2)
+		assert.Equal(t, "a", resp.GetEnrollments()[0].GetId())
+		assert.Equal(t, "b", resp.GetEnrollments()[1].GetId())
+		assert.Equal(t, int32(2), resp.GetMeta().GetPage())
+		assert.Equal(t, int32(10), resp.GetMeta().GetPerPage())
+		assert.Equal(t, int32(2), resp.GetMeta().GetPageCount())
+		assert.Equal(t, int32(12), resp.GetMeta().GetTotalCount())
+	})
+
+	t.Run("should return the meta of a response with its last modification", func(t *testing.T) {
+		client := newGRPCClient(t, enrollment.Endpoints{
+			GetAll: func(ctx context.Context, request interface{}) (interface{}, error) {
+				m, _ := meta.New(1, 10, 1, "")
+				return enrollment.CachedResponse{
+					Response:     response.OK("success", []domain.Enrollment{{ID: "a"}}, m),
+					LastModified: time.Now(),
+				}, nil
+			},
+		})
+
+		resp, err := client.GetAll(context.Background(), &pb.GetAllRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.GetEnrollments(), 1)
+		assert.Equal(t, int32(1), resp.GetMeta().GetTotalCount())
+	})
+}
+
+func TestGRPCUpdate(t *testing.T) {
+
+	t.Run("should pass the id and status to the endpoint", func(t *testing.T) {
+		client := newGRPCClient(t, enrollment.Endpoints{
+			Update: func(ctx context.Context, request interface{}) (interface{}, error) {
+				req := request.(enrollment.UpdateReq)
+				assert.Equal(t, "10010", req.ID)
+				require.NotNil(t, req.Status)
+				assert.Equal(t, "A", *req.Status)
+				return response.OK("success", nil, nil), nil
+			},
+		})
+
+		st := "A"
+		_, err := client.Update(context.Background(), &pb.UpdateRequest{Id: "10010", Status: &st})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return not found when the enrollment does not exist", func(t *testing.T) {
+		client := newGRPCClient(t, enrollment.Endpoints{
+			Update: func(ctx context.Context, request interface{}) (interface{}, error) {
+				return nil, response.NotFound(enrollment.ErrNotFound{EnrollmentsID: "10010"}.Error())
+			},
+		})
+
+		_, err := client.Update(context.Background(), &pb.UpdateRequest{Id: "10010"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
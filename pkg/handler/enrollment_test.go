@@ -0,0 +1,104 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+
+	ok := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return response.OK("success", nil, nil), nil
+	}
+	h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{
+		Create: ok,
+		GetAll: ok,
+		Update: ok,
+	})
+
+	obj := []struct {
+		tag        string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantFields enrollment.FieldErrors
+	}{
+		{
+			tag:    "should accept a valid body",
+			method: http.MethodPost, target: "/enrollments", body: `{"user_id":"1","course_id":"4"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			tag:    "should reject an unknown field",
+			method: http.MethodPost, target: "/enrollments", body: `{"user_id":"1","course_id":"4","status":"A"}`,
+			wantStatus: http.StatusBadRequest, wantCode: enrollment.CodeInvalidRequest,
+			wantFields: enrollment.FieldErrors{{Field: "status", Message: "isn't a known field"}},
+		},
+		{
+			tag:    "should reject a field of the wrong type",
+			method: http.MethodPatch, target: "/enrollments/1", body: `{"status":1}`,
+			wantStatus: http.StatusBadRequest, wantCode: enrollment.CodeInvalidRequest,
+			wantFields: enrollment.FieldErrors{{Field: "status", Message: "must be a string"}},
+		},
+		{
+			tag:    "should reject a body that isn't an object",
+			method: http.MethodPost, target: "/enrollments", body: `["1","4"]`,
+			wantStatus: http.StatusBadRequest, wantCode: enrollment.CodeInvalidRequest,
+		},
+		{
+			tag:    "should reject an empty body",
+			method: http.MethodPost, target: "/enrollments", body: ``,
+			wantStatus: http.StatusBadRequest, wantCode: enrollment.CodeInvalidRequest,
+		},
+		{
+			tag:    "should reject data after the object",
+			method: http.MethodPatch, target: "/enrollments/1", body: `{"status":"A"}{"status":"I"}`,
+			wantStatus: http.StatusBadRequest, wantCode: enrollment.CodeInvalidRequest,
+		},
+		{
+			tag:    "should reject a body over the limit",
+			method: http.MethodPost, target: "/enrollments", body: `{"user_id":"` + strings.Repeat("1", 64<<10) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: enrollment.CodeRequestTooLarge,
+		},
+		{
+			tag:    "should list every invalid query parameter",
+			method: http.MethodGet, target: "/enrollments?limit=abc&page=1.5&course_id=c-1",
+			wantStatus: http.StatusBadRequest, wantCode: enrollment.CodeInvalidRequest,
+			wantFields: enrollment.FieldErrors{
+				{Field: "limit", Message: "must be an integer"},
+				{Field: "page", Message: "must be an integer"},
+				{Field: "course_id", Message: "must be a UUID"},
+			},
+		},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			require.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			if tt.wantCode == "" {
+				return
+			}
+
+			var body enrollment.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.NotEmpty(t, body.Message)
+			assert.Equal(t, tt.wantFields, body.Fields)
+		})
+	}
+}
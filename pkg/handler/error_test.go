@@ -0,0 +1,70 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mockUserSdk "github.com/ncostamagna/go_course_sdk/user/mock"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+
+	t.Run("should return the code of an unknown route", func(t *testing.T) {
+		h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/courses", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"status":404,"code":"ROUTE_NOT_FOUND","message":"route 'GET /courses' doesn't exist"}`, rec.Body.String())
+	})
+
+	t.Run("should return the code of a method not allowed", func(t *testing.T) {
+		h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/enrollments", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.JSONEq(t, `{"status":405,"code":"METHOD_NOT_ALLOWED","message":"method 'DELETE' isn't allowed on '/enrollments'"}`, rec.Body.String())
+	})
+
+	t.Run("should return the code of an invalid body", func(t *testing.T) {
+		h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/enrollments", strings.NewReader("{")))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"INVALID_REQUEST"`)
+	})
+
+	t.Run("should log the cause of an error without sending it", func(t *testing.T) {
+		var logs bytes.Buffer
+		l := slog.New(slog.NewTextHandler(&logs, nil))
+
+		users := &mockUserSdk.UserSdkMock{GetMock: func(id string) (*domain.User, error) {
+			return nil, errors.New("dial tcp 10.0.0.7:8081: connection refused")
+		}}
+		svc := enrollment.NewService(l, users, nil, nil)
+		h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.MakeEndpoints(svc, enrollment.Config{}), handler.WithLogger(l))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/enrollments", strings.NewReader(`{"user_id":"3c9a7e52-8d1b-4f6a-b2e0-7a5d9c1e4b83","course_id":"e1f4b8d2-6a3c-4d9e-8b7f-2c5a1e9d6f34"}`)))
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.JSONEq(t, `{"status":503,"code":"DEPENDENCY_UNAVAILABLE","message":"the user service is unavailable, retry later"}`, rec.Body.String())
+		assert.Contains(t, logs.String(), `msg="request failed" code=DEPENDENCY_UNAVAILABLE`)
+		assert.Contains(t, logs.String(), "connection refused")
+	})
+}
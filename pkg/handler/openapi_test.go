@@ -0,0 +1,414 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type openAPIDoc struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func routes(t *testing.T, h http.Handler) map[string][]string {
+	t.Helper()
+
+	r, ok := h.(*mux.Router)
+	require.True(t, ok, "handler is not a *mux.Router")
+
+	registered := make(map[string][]string)
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		registered[path] = append(registered[path], methods...)
+		return nil
+	})
+	require.NoError(t, err)
+
+	return registered
+}
+
+func TestOpenAPISpec(t *testing.T) {
+
+	var doc openAPIDoc
+	require.NoError(t, json.Unmarshal(handler.OpenAPISpec(), &doc))
+	assert.True(t, strings.HasPrefix(doc.OpenAPI, "3."))
+
+	h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{})
+	registered := routes(t, h)
+
+	t.Run("every registered route should be documented", func(t *testing.T) {
+		for path, methods := range registered {
+			for _, method := range methods {
+				_, ok := doc.Paths[path][strings.ToLower(method)]
+				assert.True(t, ok, "route %s %s has no OpenAPI entry", method, path)
+			}
+		}
+	})
+
+	t.Run("every documented operation should be registered", func(t *testing.T) {
+		for path, ops := range doc.Paths {
+			for method := range ops {
+				assert.Contains(t, registered[path], strings.ToUpper(method), "OpenAPI entry %s %s has no route", method, path)
+			}
+		}
+	})
+
+	t.Run("should serve the document", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, string(handler.OpenAPISpec()), rec.Body.String())
+	})
+
+	t.Run("should serve the swagger ui", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "/openapi.json")
+	})
+}
+
+// spec is the OpenAPI document as plain JSON, to follow its references.
+type spec map[string]interface{}
+
+// operations lists the v1 operations with the request their decoder fills
+// and the data of their response, nil when it isn't JSON.
+var operations = []struct {
+	method, path string
+	req          interface{}
+	data         interface{}
+	// omit lists the data fields the operation never sends.
+	omit []string
+}{
+	{"POST", "/v1/enrollments", enrollment.CreateReq{}, domain.Enrollment{}, []string{"user", "course"}},
+	{"GET", "/v1/enrollments", enrollment.GetAllReq{}, []enrollment.ExpandedEnrollment{}, nil},
+	{"GET", "/v1/enrollments/stream", enrollment.StreamReq{}, nil, nil},
+	{"GET", "/v1/enrollments/{id}", enrollment.GetReq{}, enrollment.ExpandedEnrollment{}, nil},
+	{"PATCH", "/v1/enrollments/{id}", enrollment.UpdateReq{}, nil, nil},
+	{"POST", "/v1/enrollments/{id}/progress", enrollment.RecordProgressReq{}, enrollment.Progress{}, nil},
+	{"GET", "/v1/enrollments/{id}/progress", enrollment.GetProgressReq{}, enrollment.Progress{}, nil},
+	{"GET", "/v1/enrollments/{id}/certificate", enrollment.GetCertificateReq{}, enrollment.Certificate{}, nil},
+	{"GET", "/v1/enrollments/{id}/certificate/pdf", enrollment.GetCertificateReq{}, nil, nil},
+	{"GET", "/v1/certificates/{code}/verify", enrollment.VerifyCertificateReq{}, enrollment.CertificateVerification{}, nil},
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+
+	var s spec
+	require.NoError(t, json.Unmarshal(handler.OpenAPISpec(), &s))
+
+	for _, op := range operations {
+		o := s.operation(op.method, op.path)
+		require.NotNil(t, o, "%s %s isn't documented", op.method, op.path)
+		name := op.method + " " + op.path
+
+		t.Run(name+" should document the parameters of its request", func(t *testing.T) {
+			params, body := requestFields(reflect.TypeOf(op.req))
+			var path, query []string
+			for _, p := range params {
+				if strings.Contains(op.path, "{"+p+"}") {
+					path = append(path, p)
+				} else {
+					query = append(query, p)
+				}
+			}
+
+			assert.ElementsMatch(t, path, s.parameters(o, "path"), "path parameters")
+			assert.ElementsMatch(t, query, s.parameters(o, "query"), "query parameters")
+
+			reqBody, ok := o["requestBody"].(map[string]interface{})
+			if len(body) == 0 {
+				assert.False(t, ok, "the request has no body")
+				return
+			}
+			require.True(t, ok, "the request body isn't documented")
+			schema := s.resolve(reqBody["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"])
+			s.assertProperties(t, "body", schema, body, nil)
+		})
+
+		t.Run(name+" should document the data of its response", func(t *testing.T) {
+			schema := s.responseData(o)
+			if op.data == nil {
+				assert.Nil(t, schema, "the response has no data")
+				return
+			}
+			require.NotNil(t, schema, "the response data isn't documented")
+
+			typ := reflect.TypeOf(op.data)
+			if typ.Kind() == reflect.Slice {
+				assert.Equal(t, "array", schema["type"], "data")
+				schema, typ = s.resolve(schema["items"]), typ.Elem()
+			}
+			s.assertProperties(t, "data", schema, jsonFields(typ), op.omit)
+		})
+	}
+
+	t.Run("should document every error code of the catalog", func(t *testing.T) {
+		codes := constants(t, "Code")
+		require.NotEmpty(t, codes)
+
+		errResp := s.resolve(map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"})
+		s.assertProperties(t, "ErrorResponse", errResp, jsonFields(reflect.TypeOf(enrollment.ErrorResponse{})), nil)
+		assert.ElementsMatch(t, codes, enum(s.properties(errResp)["code"]))
+
+		expandErr := s.resolve(map[string]interface{}{"$ref": "#/components/schemas/ExpandError"})
+		assert.Subset(t, codes, enum(s.properties(expandErr)["code"]))
+	})
+
+	t.Run("should document every status of an enrollment", func(t *testing.T) {
+		statuses := constants(t, "Status")
+		require.NotEmpty(t, statuses)
+
+		for _, name := range []string{"Enrollment", "Progress", "UpdateReq"} {
+			schema := s.resolve(map[string]interface{}{"$ref": "#/components/schemas/" + name})
+			assert.ElementsMatch(t, statuses, enum(s.properties(schema)["status"]), name)
+		}
+	})
+}
+
+func (s spec) operation(method, path string) map[string]interface{} {
+	paths, _ := s["paths"].(map[string]interface{})
+	ops, _ := paths[path].(map[string]interface{})
+	op, _ := ops[strings.ToLower(method)].(map[string]interface{})
+	return op
+}
+
+// resolve follows the $ref of a schema, parameter or response.
+func (s spec) resolve(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	ref, ok := m["$ref"].(string)
+	if !ok {
+		return m
+	}
+	var cur interface{} = map[string]interface{}(s)
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		cur = cur.(map[string]interface{})[key]
+	}
+	return s.resolve(cur)
+}
+
+// parameters returns the names of the parameters of an operation in the
+// path, the query or the headers.
+func (s spec) parameters(op map[string]interface{}, in string) []string {
+	var names []string
+	params, _ := op["parameters"].([]interface{})
+	for _, p := range params {
+		param := s.resolve(p)
+		if param["in"] == in {
+			names = append(names, param["name"].(string))
+		}
+	}
+	return names
+}
+
+// properties returns the properties of an object schema, merging the ones
+// of its allOf.
+func (s spec) properties(schema map[string]interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	for _, sub := range asSlice(schema["allOf"]) {
+		for k, v := range s.properties(s.resolve(sub)) {
+			props[k] = v
+		}
+	}
+	for k, v := range asMap(schema["properties"]) {
+		props[k] = v
+	}
+	return props
+}
+
+// responseData returns the schema of the data of the success response, nil
+// when it's only the nullable data of the envelope.
+func (s spec) responseData(op map[string]interface{}) map[string]interface{} {
+	for _, code := range []string{"200", "201"} {
+		resp := s.resolve(asMap(op["responses"])[code])
+		schema := s.resolve(asMap(asMap(resp["content"])["application/json"])["schema"])
+		data := s.resolve(s.properties(schema)["data"])
+		if data["type"] != nil || data["allOf"] != nil {
+			return data
+		}
+	}
+	return nil
+}
+
+// assertProperties checks that a schema has the fields of a Go type, with
+// their JSON types, following the nested objects.
+func (s spec) assertProperties(t *testing.T, at string, schema map[string]interface{}, fields map[string]reflect.Type, omit []string) {
+	t.Helper()
+
+	props := s.properties(schema)
+	var want, got []string
+	for name := range fields {
+		if !slices.Contains(omit, name) {
+			want = append(want, name)
+		}
+	}
+	for name := range props {
+		got = append(got, name)
+	}
+	if !assert.ElementsMatch(t, want, got, "%s: properties", at) {
+		return
+	}
+
+	for _, name := range want {
+		s.assertType(t, at+"."+name, s.resolve(props[name]), fields[name])
+	}
+}
+
+func (s spec) assertType(t *testing.T, at string, schema map[string]interface{}, typ reflect.Type) {
+	t.Helper()
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		assert.Equal(t, "string", schema["type"], at)
+		assert.Equal(t, "date-time", schema["format"], at)
+	case typ.Kind() == reflect.Struct:
+		s.assertProperties(t, at, schema, jsonFields(typ), nil)
+	case typ.Kind() == reflect.Slice:
+		if assert.Equal(t, "array", schema["type"], at) {
+			s.assertType(t, at+"[]", s.resolve(schema["items"]), typ.Elem())
+		}
+	case typ.Kind() == reflect.String:
+		assert.Equal(t, "string", schema["type"], at)
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		assert.Equal(t, "integer", schema["type"], at)
+	case typ.Kind() == reflect.Bool:
+		assert.Equal(t, "boolean", schema["type"], at)
+	}
+}
+
+// requestFields splits the fields of a request between the parameters, the
+// fields without a JSON name named in snake case, and the body.
+func requestFields(typ reflect.Type) ([]string, map[string]reflect.Type) {
+	var params []string
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.Tag.Get("json") == "" {
+			params = append(params, snakeCase(f.Name))
+		}
+	}
+	return params, jsonFields(typ)
+}
+
+// jsonFields returns the fields encoding/json writes, by their JSON name.
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(s[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// constants returns the values of the string constants of the enrollment
+// package whose name starts with prefix.
+func constants(t *testing.T, prefix string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob("../../internal/enrollment/*.go")
+	require.NoError(t, err)
+
+	var values []string
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		require.NoError(t, err)
+
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, id := range vs.Names {
+					if !strings.HasPrefix(id.Name, prefix) || i >= len(vs.Values) {
+						continue
+					}
+					lit, ok := vs.Values[i].(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						continue
+					}
+					v, _ := strconv.Unquote(lit.Value)
+					values = append(values, v)
+				}
+			}
+		}
+	}
+	return values
+}
+
+func enum(v interface{}) []string {
+	var values []string
+	for _, e := range asSlice(asMap(v)["enum"]) {
+		values = append(values, e.(string))
+	}
+	return values
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/ratelimit"
+)
+
+// RateLimits configures the rate limiting of the enrollment routes. Reads
+// (GET and HEAD) and writes are limited separately, and Routes overrides the
+// limit of a route by its name. A nil Store disables the rate limiting.
+type RateLimits struct {
+	Store  ratelimit.Store
+	Key    ratelimit.KeyFunc
+	Read   ratelimit.Limit
+	Write  ratelimit.Limit
+	Routes map[string]ratelimit.Limit
+}
+
+// rateLimitMiddleware limits the named routes. A route with its own limit has
+// its own buckets, the rest share the read or write bucket of the client.
+func rateLimitMiddleware(cfg RateLimits, l *slog.Logger) mux.MiddlewareFunc {
+	key := cfg.Key
+	if key == nil {
+		key = ratelimit.ClientKey
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if cfg.Store == nil || route == nil || route.GetName() == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bucket, limit := "write", cfg.Write
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				bucket, limit = "read", cfg.Read
+			}
+			if l, ok := cfg.Routes[route.GetName()]; ok {
+				bucket, limit = route.GetName(), l
+			}
+			if !limit.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := cfg.Store.Take(r.Context(), bucket+":"+key(r), limit)
+			if err != nil {
+				// a broken store must not take the API down
+				l.ErrorContext(r.Context(), "taking a rate limit token", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", ceilSeconds(res.Reset))
+
+			if !res.Allowed {
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				encodeError(context.Background(), enrollment.NewError(
+					http.StatusTooManyRequests, enrollment.CodeRateLimited, "too many requests, retry later"), w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,178 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store down")
+}
+
+func rateLimitedServer(cfg handler.RateLimits) http.Handler {
+	ok := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return response.OK("success", nil, nil), nil
+	}
+	return handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{
+		Create: ok,
+		GetAll: ok,
+		Update: ok,
+	}, handler.WithRateLimits(cfg))
+}
+
+// call sends a request from the client IP address.
+func call(h http.Handler, method, target, client string) *httptest.ResponseRecorder {
+	var body *strings.Reader
+	switch method {
+	case http.MethodPost:
+		body = strings.NewReader(`{"user_id":"1","course_id":"4"}`)
+	case http.MethodPatch:
+		body = strings.NewReader(`{"status":"A"}`)
+	default:
+		body = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, target, body)
+	req.RemoteAddr = client + ":1234"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+
+	t.Run("should reject the requests over the limit", func(t *testing.T) {
+		h := rateLimitedServer(handler.RateLimits{
+			Store: ratelimit.NewMemoryStore(),
+			Write: ratelimit.Limit{Requests: 2, Period: time.Minute},
+		})
+
+		rec := call(h, http.MethodPost, "/enrollments", "192.0.2.1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "30", rec.Header().Get("X-RateLimit-Reset"))
+
+		call(h, http.MethodPost, "/enrollments", "192.0.2.1")
+
+		rec = call(h, http.MethodPost, "/enrollments", "192.0.2.1")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+		assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+		assert.JSONEq(t, `{"status":429,"code":"RATE_LIMITED","message":"too many requests, retry later"}`, rec.Body.String())
+
+		assert.Equal(t, http.StatusOK, call(h, http.MethodPost, "/enrollments", "192.0.2.2").Code)
+	})
+
+	t.Run("should not give a client a new bucket for each api key", func(t *testing.T) {
+		h := rateLimitedServer(handler.RateLimits{
+			Store: ratelimit.NewMemoryStore(),
+			Read:  ratelimit.Limit{Requests: 1, Period: time.Minute},
+		})
+
+		for i, code := range []int{http.StatusOK, http.StatusTooManyRequests} {
+			req := httptest.NewRequest(http.MethodGet, "/enrollments", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-API-Key", fmt.Sprintf("k%d", i))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			assert.Equal(t, code, rec.Code)
+		}
+	})
+
+	t.Run("should use the key function", func(t *testing.T) {
+		trusted, err := ratelimit.ParseProxies("10.0.0.0/8")
+		require.NoError(t, err)
+		h := rateLimitedServer(handler.RateLimits{
+			Store: ratelimit.NewMemoryStore(),
+			Key:   ratelimit.ProxyClientKey(trusted),
+			Read:  ratelimit.Limit{Requests: 1, Period: time.Minute},
+		})
+
+		for _, client := range []string{"198.51.100.7", "198.51.100.8"} {
+			req := httptest.NewRequest(http.MethodGet, "/enrollments", nil)
+			req.RemoteAddr = "10.0.0.5:1234"
+			req.Header.Set("X-Forwarded-For", client)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code, client)
+		}
+	})
+
+	t.Run("should limit reads and writes separately", func(t *testing.T) {
+		h := rateLimitedServer(handler.RateLimits{
+			Store: ratelimit.NewMemoryStore(),
+			Read:  ratelimit.Limit{Requests: 1, Period: time.Minute},
+			Write: ratelimit.Limit{Requests: 1, Period: time.Minute},
+		})
+
+		assert.Equal(t, http.StatusOK, call(h, http.MethodPost, "/enrollments", "192.0.2.1").Code)
+		assert.Equal(t, http.StatusOK, call(h, http.MethodGet, "/enrollments", "192.0.2.1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, call(h, http.MethodPatch, "/enrollments/1", "192.0.2.1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, call(h, http.MethodGet, "/enrollments", "192.0.2.1").Code)
+	})
+
+	t.Run("should apply the limit of the route", func(t *testing.T) {
+		h := rateLimitedServer(handler.RateLimits{
+			Store: ratelimit.NewMemoryStore(),
+			Write: ratelimit.Limit{Requests: 10, Period: time.Minute},
+			Routes: map[string]ratelimit.Limit{
+				handler.RouteCreate: {Requests: 1, Period: time.Minute},
+			},
+		})
+
+		assert.Equal(t, http.StatusOK, call(h, http.MethodPost, "/enrollments", "192.0.2.1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, call(h, http.MethodPost, "/enrollments", "192.0.2.1").Code)
+		assert.Equal(t, http.StatusOK, call(h, http.MethodPatch, "/enrollments/1", "192.0.2.1").Code)
+	})
+
+	t.Run("should not limit a route with a zero limit", func(t *testing.T) {
+		h := rateLimitedServer(handler.RateLimits{
+			Store: ratelimit.NewMemoryStore(),
+			Read:  ratelimit.Limit{Requests: 1, Period: time.Minute},
+			Routes: map[string]ratelimit.Limit{
+				handler.RouteGetAll: {},
+			},
+		})
+
+		for i := 0; i < 3; i++ {
+			rec := call(h, http.MethodGet, "/enrollments", "192.0.2.1")
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Empty(t, rec.Header().Get("X-RateLimit-Limit"))
+		}
+	})
+
+	t.Run("should not limit the docs", func(t *testing.T) {
+		h := rateLimitedServer(handler.RateLimits{
+			Store: ratelimit.NewMemoryStore(),
+			Read:  ratelimit.Limit{Requests: 1, Period: time.Minute},
+		})
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, call(h, http.MethodGet, "/openapi.json", "192.0.2.1").Code)
+		}
+	})
+
+	t.Run("should allow the requests when the store fails", func(t *testing.T) {
+		h := rateLimitedServer(handler.RateLimits{
+			Store: failingStore{},
+			Write: ratelimit.Limit{Requests: 1, Period: time.Minute},
+		})
+
+		assert.Equal(t, http.StatusOK, call(h, http.MethodPost, "/enrollments", "192.0.2.1").Code)
+	})
+}
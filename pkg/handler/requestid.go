@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/logger"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware keeps the X-Request-ID received from the client, or generates
+// a new one, stores it in the request context and returns it in the response.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	})
+}
+
+// grpcRequestID does the same as requestIDMiddleware with the x-request-id metadata.
+func grpcRequestID(ctx context.Context, md metadata.MD) context.Context {
+	id := uuid.NewString()
+	if v := md.Get(requestIDHeader); len(v) > 0 && v[0] != "" {
+		id = v[0]
+	}
+	return logger.WithRequestID(ctx, id)
+}
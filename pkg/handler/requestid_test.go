@@ -0,0 +1,43 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ncostamagna/go_lib_response/response"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+
+	var requestID string
+	h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{
+		GetAll: func(ctx context.Context, request interface{}) (interface{}, error) {
+			requestID = logger.RequestID(ctx)
+			return response.OK("success", nil, nil), nil
+		},
+	})
+
+	t.Run("should propagate the request id received", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/enrollments", nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, "req-1", requestID)
+		assert.Equal(t, "req-1", rec.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("should generate a request id when it is missing", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/enrollments", nil))
+
+		assert.NotEmpty(t, requestID)
+		assert.Equal(t, requestID, rec.Header().Get("X-Request-ID"))
+	})
+}
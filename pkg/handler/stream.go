@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+)
+
+// defaultHeartbeat is the heartbeat of the event stream when it isn't set.
+const defaultHeartbeat = 15 * time.Second
+
+// EventStream configures GET /enrollments/stream, which answers not found
+// without a broker. The heartbeat keeps the idle connections open through
+// the proxies.
+type EventStream struct {
+	Broker    *enrollment.Broker
+	Heartbeat time.Duration
+}
+
+// WithEventStream serves the enrollment changes published to the broker as
+// Server-Sent Events.
+func WithEventStream(cfg EventStream) ServerOption {
+	return func(o *serverOptions) {
+		o.eventStream = cfg
+	}
+}
+
+// streamEnrollments sends the events that match the user_id and course_id
+// filters until the client leaves, the server shuts down (ctx is done) or
+// the client falls behind. A client resuming with Last-Event-ID first gets
+// the buffered events it missed, or a reset event when they aren't buffered
+// anymore or the id isn't one of ours.
+func streamEnrollments(ctx context.Context, cfg EventStream, l *slog.Logger) http.HandlerFunc {
+	heartbeat := cfg.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.Broker == nil {
+			encodeError(r.Context(), enrollment.NewError(http.StatusNotFound, enrollment.CodeRouteNotFound, "the event stream isn't enabled"), w)
+			return
+		}
+
+		v := r.URL.Query()
+		req := enrollment.StreamReq{
+			UserID:      v.Get("user_id"),
+			CourseID:    v.Get("course_id"),
+			LastEventID: r.Header.Get("Last-Event-ID"),
+		}
+		if req.LastEventID == "" {
+			req.LastEventID = v.Get("last_event_id")
+		}
+		if errs := enrollment.Validate(req); errs != nil {
+			encodeError(r.Context(), enrollment.InvalidFields(errs), w)
+			return
+		}
+
+		sub := cfg.Broker.Subscribe(enrollment.Filters{UserID: req.UserID, CourseID: req.CourseID}, req.LastEventID)
+		defer sub.Close()
+
+		// the stream outlives the write timeout of the server
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			l.WarnContext(r.Context(), "clearing the write deadline of the event stream", "error", err)
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", cacheControlNoStore)
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(write func() error) bool {
+			if err := write(); err != nil {
+				return false
+			}
+			return rc.Flush() == nil
+		}
+
+		if sub.Missed {
+			if !send(func() error { return writeReset(w, sub.ResumeID) }) {
+				return
+			}
+		}
+		for _, ev := range sub.Replay {
+			if !send(func() error { return writeEvent(w, ev) }) {
+				return
+			}
+		}
+		if !send(func() error { return nil }) {
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ctx.Done():
+				return
+			case ev, ok := <-sub.C:
+				if !ok {
+					l.WarnContext(r.Context(), "event stream client fell behind, closing the stream")
+					return
+				}
+				if !send(func() error { return writeEvent(w, ev) }) {
+					return
+				}
+			case <-ticker.C:
+				if !send(func() error { _, err := io.WriteString(w, ": heartbeat\n\n"); return err }) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func writeEvent(w io.Writer, ev enrollment.Event) error {
+	data, err := json.Marshal(ev.Enrollment)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
+
+// writeReset tells the client to reload the enrollments, the events it
+// missed aren't buffered anymore.
+func writeReset(w io.Writer, resumeID string) error {
+	_, err := fmt.Fprintf(w, "id: %s\nevent: enrollment.reset\ndata: {}\n\n", resumeID)
+	return err
+}
@@ -0,0 +1,210 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	id, event, data, comment string
+}
+
+// openStream connects to the stream, the connection is closed with the test.
+func openStream(t *testing.T, url string, header map[string]string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readEvent reads the lines up to the blank line that ends an event.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return ev
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			ev.id = value
+		case "event":
+			ev.event = value
+		case "data":
+			ev.data = value
+		case "":
+			ev.comment = value
+		}
+	}
+}
+
+func newStreamServer(t *testing.T, ctx context.Context, cfg handler.EventStream) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(handler.NewEnrollmentHTTPServer(ctx, enrollment.Endpoints{}, handler.WithEventStream(cfg)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// waitSubscribers waits for the stream handlers to subscribe.
+func waitSubscribers(t *testing.T, b *enrollment.Broker, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return b.Subscribers() == n }, time.Second, time.Millisecond)
+}
+
+func TestStream(t *testing.T) {
+
+	t.Run("should stream the changes that match the filters", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		srv := newStreamServer(t, context.Background(), handler.EventStream{Broker: b, Heartbeat: time.Minute})
+
+		resp, r := openStream(t, srv.URL+"/enrollments/stream?user_id=0b4f5c3e-8c6e-4a2b-9f4e-3f1d2c7a1e01", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+		waitSubscribers(t, b, 1)
+
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "1", UserID: "0b4f5c3e-8c6e-4a2b-9f4e-3f1d2c7a1e02", CourseID: "c-1", Status: "P"})
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "2", UserID: "0b4f5c3e-8c6e-4a2b-9f4e-3f1d2c7a1e01", CourseID: "c-1", Status: "P"})
+
+		ev := readEvent(t, r)
+		assert.NotEmpty(t, ev.id)
+		assert.Equal(t, "enrollment.created", ev.event)
+		assert.JSONEq(t, `{"id":"2","user_id":"0b4f5c3e-8c6e-4a2b-9f4e-3f1d2c7a1e01","course_id":"c-1","status":"P"}`, ev.data)
+	})
+
+	t.Run("should resume after the last event id", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		sub := b.Subscribe(enrollment.Filters{}, "")
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "1"})
+		b.Publish(enrollment.EventUpdated, domain.Enrollment{ID: "1", Status: "A"})
+		last := (<-sub.C).ID
+
+		srv := newStreamServer(t, context.Background(), handler.EventStream{Broker: b, Heartbeat: time.Minute})
+
+		for _, header := range []map[string]string{{"Last-Event-ID": last}, nil} {
+			url := srv.URL + "/enrollments/stream"
+			if header == nil {
+				url += "?last_event_id=" + last
+			}
+			_, r := openStream(t, url, header)
+
+			ev := readEvent(t, r)
+			assert.Equal(t, "enrollment.updated", ev.event)
+			assert.JSONEq(t, `{"id":"1","course_id":"","status":"A"}`, ev.data)
+		}
+	})
+
+	t.Run("should send a reset when the missed events aren't buffered", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		srv := newStreamServer(t, context.Background(), handler.EventStream{Broker: b, Heartbeat: time.Minute})
+
+		_, r := openStream(t, srv.URL+"/enrollments/stream", map[string]string{"Last-Event-ID": "previous-3"})
+
+		ev := readEvent(t, r)
+		assert.Equal(t, "enrollment.reset", ev.event)
+		assert.NotEmpty(t, ev.id)
+	})
+
+	t.Run("should send heartbeats", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		srv := newStreamServer(t, context.Background(), handler.EventStream{Broker: b, Heartbeat: 10 * time.Millisecond})
+
+		_, r := openStream(t, srv.URL+"/enrollments/stream", nil)
+
+		assert.Equal(t, sseEvent{comment: "heartbeat"}, readEvent(t, r))
+	})
+
+	t.Run("should end the streams when the server shuts down", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := newStreamServer(t, ctx, handler.EventStream{Broker: b, Heartbeat: time.Minute})
+
+		_, r := openStream(t, srv.URL+"/enrollments/stream", nil)
+		waitSubscribers(t, b, 1)
+
+		cancel()
+		_, err := r.ReadString('\n')
+		assert.Error(t, err)
+		waitSubscribers(t, b, 0)
+	})
+
+	t.Run("should unsubscribe when the client leaves", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		srv := newStreamServer(t, context.Background(), handler.EventStream{Broker: b, Heartbeat: time.Minute})
+
+		resp, _ := openStream(t, srv.URL+"/enrollments/stream", nil)
+		waitSubscribers(t, b, 1)
+
+		resp.Body.Close()
+		waitSubscribers(t, b, 0)
+	})
+
+	t.Run("should send a reset and stream from now with an invalid event id", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		b.Publish(enrollment.EventCreated, domain.Enrollment{ID: "1"})
+		srv := newStreamServer(t, context.Background(), handler.EventStream{Broker: b, Heartbeat: time.Minute})
+
+		resp, r := openStream(t, srv.URL+"/enrollments/stream", map[string]string{"Last-Event-ID": "42"})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		reset := readEvent(t, r)
+		assert.Equal(t, "enrollment.reset", reset.event)
+		assert.NotEmpty(t, reset.id)
+
+		b.Publish(enrollment.EventUpdated, domain.Enrollment{ID: "1", Status: "A"})
+		ev := readEvent(t, r)
+		assert.Equal(t, "enrollment.updated", ev.event)
+		assert.JSONEq(t, `{"id":"1","course_id":"","status":"A"}`, ev.data)
+	})
+
+	t.Run("should return bad request with invalid filters", func(t *testing.T) {
+		b := enrollment.NewBroker(10, 10)
+		h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{}, handler.WithEventStream(handler.EventStream{Broker: b}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/enrollments/stream?user_id=u-1&course_id=c-1", nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"status":400,"code":"INVALID_REQUEST","message":"user_id: must be a UUID; course_id: must be a UUID",
+			"fields":[{"field":"user_id","message":"must be a UUID"},{"field":"course_id","message":"must be a UUID"}]}`, rec.Body.String())
+		assert.Zero(t, b.Subscribers())
+	})
+
+	t.Run("should return not found when the stream isn't enabled", func(t *testing.T) {
+		h := handler.NewEnrollmentHTTPServer(context.Background(), enrollment.Endpoints{})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/enrollments/stream", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"ROUTE_NOT_FOUND"`)
+	})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Versions of the HTTP API. VersionLegacy is the unversioned routes of the
+// first release, kept as aliases of VersionV1.
+const (
+	VersionLegacy = "legacy"
+	VersionV1     = "v1"
+)
+
+type (
+	// Deprecation announces the removal of a version of the API. Its routes
+	// send the Deprecation header (RFC 9745) from Since on, which can be in
+	// the future, and the Sunset header (RFC 8594) when Sunset is set.
+	Deprecation struct {
+		Since  time.Time
+		Sunset time.Time
+	}
+
+	// apiVersion mounts the routes of a version under its prefix, the
+	// routes of a version without prefix are mounted at the root.
+	apiVersion struct {
+		name   string
+		prefix string
+		// successor is the prefix of the version replacing this one, sent
+		// in a Link header when it's deprecated.
+		successor string
+		routes    func(r *mux.Router)
+	}
+)
+
+// WithDeprecation deprecates a version of the API.
+func WithDeprecation(version string, d Deprecation) ServerOption {
+	return func(o *serverOptions) {
+		if o.deprecations == nil {
+			o.deprecations = make(map[string]Deprecation)
+		}
+		o.deprecations[version] = d
+	}
+}
+
+// mountVersions registers the routes of every version. Each version gets its
+// own subrouter so its middlewares don't run on the routes of the others.
+func mountVersions(r *mux.Router, deprecations map[string]Deprecation, versions []apiVersion) {
+	for _, v := range versions {
+		sub := r.NewRoute().Subrouter()
+		if v.prefix != "" {
+			sub = r.PathPrefix(v.prefix).Subrouter()
+		}
+		if d, ok := deprecations[v.name]; ok {
+			sub.Use(deprecationMiddleware(d, v))
+		}
+		v.routes(sub)
+	}
+}
+
+func deprecationMiddleware(d Deprecation, v apiVersion) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+			if !d.Sunset.IsZero() {
+				h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if v.successor != "" {
+				path := v.successor + strings.TrimPrefix(r.URL.Path, v.prefix)
+				h.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, path))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
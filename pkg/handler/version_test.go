@@ -0,0 +1,65 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/handler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersions(t *testing.T) {
+	since := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// the endpoints reject an id that isn't a UUID before calling the service
+	endpoints := enrollment.MakeEndpoints(nil, enrollment.Config{})
+
+	t.Run("should serve the same routes under /v1 and without prefix", func(t *testing.T) {
+		h := handler.NewEnrollmentHTTPServer(context.Background(), endpoints)
+
+		for _, path := range []string{"/v1/enrollments/x", "/enrollments/x"} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+			assert.Contains(t, rec.Body.String(), `"field":"id"`, path)
+			assert.Empty(t, rec.Header().Get("Deprecation"), path)
+		}
+	})
+
+	t.Run("should announce the deprecation of the legacy routes", func(t *testing.T) {
+		h := handler.NewEnrollmentHTTPServer(context.Background(), endpoints,
+			handler.WithDeprecation(handler.VersionLegacy, handler.Deprecation{Since: since, Sunset: sunset}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/enrollments/x", nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "@1793491200", rec.Header().Get("Deprecation"))
+		assert.Equal(t, "Sat, 01 May 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+		assert.Equal(t, `</v1/enrollments/x>; rel="successor-version"`, rec.Header().Get("Link"))
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/enrollments/x", nil))
+
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+		assert.Empty(t, rec.Header().Get("Link"))
+	})
+
+	t.Run("should not send a sunset or a successor when there's none", func(t *testing.T) {
+		h := handler.NewEnrollmentHTTPServer(context.Background(), endpoints,
+			handler.WithDeprecation(handler.VersionV1, handler.Deprecation{Since: since}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/enrollments/x", nil))
+
+		assert.Equal(t, "@1793491200", rec.Header().Get("Deprecation"))
+		assert.Empty(t, rec.Header().Get("Sunset"))
+		assert.Empty(t, rec.Header().Get("Link"))
+	})
+}
@@ -0,0 +1,275 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"gorm.io/gorm"
+
+	courseSdk "github.com/ncostamagna/go_course_sdk/course"
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// probeID is requested to the user and course services on readiness checks,
+// a not found answer means the service is up. It's a well-formed UUID, so a
+// service validating the ids answers not found rather than bad request.
+const probeID = "00000000-0000-4000-8000-000000000000"
+
+type (
+	// Check verifies a dependency, it must honor the context deadline.
+	Check func(ctx context.Context) error
+
+	CheckResult struct {
+		Status   string `json:"status"`
+		Duration string `json:"duration"`
+		Error    string `json:"error,omitempty"`
+	}
+
+	Report struct {
+		Status string                 `json:"status"`
+		Checks map[string]CheckResult `json:"checks,omitempty"`
+	}
+
+	Checker struct {
+		checks  map[string]Check
+		timeout time.Duration
+		ttl     time.Duration
+
+		mu       sync.Mutex
+		ready    bool
+		report   Report
+		cachedAt time.Time
+		// running is the run of the checks in progress, the concurrent
+		// probes wait for it instead of running the checks again.
+		running *run
+		// version changes with the checks and the readiness, a run started
+		// before the change isn't cached.
+		version int
+	}
+
+	run struct {
+		done   chan struct{}
+		report Report
+	}
+
+	userGetter interface {
+		GetContext(ctx context.Context, id string) (*domain.User, error)
+	}
+
+	courseGetter interface {
+		GetContext(ctx context.Context, id string) (*domain.Course, error)
+	}
+)
+
+// NewChecker runs every check with the timeout and caches the readiness report for ttl.
+func NewChecker(timeout, ttl time.Duration) *Checker {
+	return &Checker{
+		checks:  make(map[string]Check),
+		timeout: timeout,
+		ttl:     ttl,
+		ready:   true,
+	}
+}
+
+// Add registers a readiness check.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+	c.cachedAt = time.Time{}
+	c.version++
+}
+
+// SetReady marks the service as ready or not, regardless of the checks.
+// It's used to fail the readiness probe while the server shuts down.
+func (c *Checker) SetReady(ready bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ready = ready
+	c.cachedAt = time.Time{}
+	c.version++
+}
+
+// Ready runs the checks, or returns the cached report while it's fresh. The
+// checks run without holding the lock, so SetReady doesn't wait for them.
+// Concurrent probes share one run, which outlives the probe that started it:
+// only the timeout of each check bounds it.
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mu.Lock()
+	if !c.ready {
+		c.mu.Unlock()
+		return Report{Status: StatusFail}
+	}
+	if !c.cachedAt.IsZero() && time.Since(c.cachedAt) < c.ttl {
+		defer c.mu.Unlock()
+		return c.report
+	}
+
+	r := c.running
+	if r == nil {
+		r = &run{done: make(chan struct{})}
+		c.running = r
+		go c.start(context.WithoutCancel(ctx), r, maps.Clone(c.checks), c.version)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return Report{Status: StatusFail}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the service may have been marked as not ready while the checks ran
+	if !c.ready {
+		return Report{Status: StatusFail}
+	}
+	return r.report
+}
+
+// start runs the checks for r and caches the report, unless a check was
+// added or the readiness changed meanwhile.
+func (c *Checker) start(ctx context.Context, r *run, checks map[string]Check, version int) {
+	r.report = c.run(ctx, checks)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = nil
+	if c.version == version {
+		c.report, c.cachedAt = r.report, time.Now()
+	}
+	close(r.done)
+}
+
+func (c *Checker) run(ctx context.Context, checks map[string]Check) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			res := c.runCheck(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checker) runCheck(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	begin := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := CheckResult{Status: StatusOK, Duration: time.Since(begin).String()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// LiveHandler answers the liveness probe, it only tells the process is serving requests.
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+// ReadyHandler answers the readiness probe with the report of every dependency.
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Ready(r.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	})
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// DBCheck pings the database.
+func DBCheck(db *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// UserCheck requests an unknown user to the user service. Pass it the
+// transport the service wraps, the probes aren't calls of the service.
+func UserCheck(trans userSdk.Transport) Check {
+	return func(ctx context.Context) error {
+		var err error
+		if t, ok := trans.(userGetter); ok {
+			_, err = t.GetContext(ctx, probeID)
+		} else {
+			_, err = trans.Get(probeID)
+		}
+		if err == nil || errors.As(err, &userSdk.ErrNotFound{}) {
+			return nil
+		}
+		return err
+	}
+}
+
+// CourseCheck requests an unknown course to the course service.
+func CourseCheck(trans courseSdk.Transport) Check {
+	return func(ctx context.Context) error {
+		var err error
+		if t, ok := trans.(courseGetter); ok {
+			_, err = t.GetContext(ctx, probeID)
+		} else {
+			_, err = trans.Get(probeID)
+		}
+		if err == nil || errors.As(err, &courseSdk.ErrNotFound{}) {
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,260 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
+	mockUserSdk "github.com/ncostamagna/go_course_sdk/user/mock"
+)
+
+func ready(t *testing.T, c *health.Checker) (int, health.Report) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
+
+func TestLiveHandler(t *testing.T) {
+	c := health.NewChecker(time.Second, 0)
+	c.Add("database", func(ctx context.Context) error { return errors.New("down") })
+
+	rec := httptest.NewRecorder()
+	c.LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestReadyHandler(t *testing.T) {
+
+	t.Run("should be ready when every check passes", func(t *testing.T) {
+		c := health.NewChecker(time.Second, 0)
+		c.Add("database", func(ctx context.Context) error { return nil })
+		c.Add("user_service", func(ctx context.Context) error { return nil })
+
+		code, report := ready(t, c)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusOK, report.Status)
+		assert.Equal(t, health.StatusOK, report.Checks["database"].Status)
+		assert.Equal(t, health.StatusOK, report.Checks["user_service"].Status)
+	})
+
+	t.Run("should report the failed dependency", func(t *testing.T) {
+		c := health.NewChecker(time.Second, 0)
+		c.Add("database", func(ctx context.Context) error { return nil })
+		c.Add("user_service", func(ctx context.Context) error { return errors.New("connection refused") })
+
+		code, report := ready(t, c)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.StatusFail, report.Status)
+		assert.Equal(t, health.StatusOK, report.Checks["database"].Status)
+		assert.Equal(t, health.StatusFail, report.Checks["user_service"].Status)
+		assert.Equal(t, "connection refused", report.Checks["user_service"].Error)
+	})
+
+	t.Run("should fail a check that exceeds the timeout", func(t *testing.T) {
+		c := health.NewChecker(10*time.Millisecond, 0)
+		c.Add("database", func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+
+		code, report := ready(t, c)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["database"].Error)
+	})
+
+	t.Run("should cache the report", func(t *testing.T) {
+		var calls int32
+		c := health.NewChecker(time.Second, time.Minute)
+		c.Add("database", func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		})
+
+		ready(t, c)
+		ready(t, c)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("should not block SetReady while the checks run", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		c := health.NewChecker(time.Minute, time.Minute)
+		c.Add("database", func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+
+		done := make(chan int)
+		go func() {
+			code, _ := ready(t, c)
+			done <- code
+		}()
+		<-started
+
+		marked := make(chan struct{})
+		go func() {
+			c.SetReady(false)
+			close(marked)
+		}()
+		select {
+		case <-marked:
+		case <-time.After(time.Second):
+			t.Fatal("SetReady waited for the checks")
+		}
+
+		close(release)
+		assert.Equal(t, http.StatusServiceUnavailable, <-done)
+	})
+
+	t.Run("should share the running checks between concurrent probes", func(t *testing.T) {
+		var calls int32
+		started, release := make(chan struct{}), make(chan struct{})
+		c := health.NewChecker(time.Minute, 0)
+		c.Add("database", func(ctx context.Context) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+			}
+			<-release
+			return nil
+		})
+
+		codes := make(chan int, 3)
+		go func() {
+			code, _ := ready(t, c)
+			codes <- code
+		}()
+		<-started
+		for range 2 {
+			go func() {
+				code, _ := ready(t, c)
+				codes <- code
+			}()
+		}
+		// let the other probes reach the running checks
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		for range 3 {
+			assert.Equal(t, http.StatusOK, <-codes)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("should not fail the shared checks when the probe that started them leaves", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		c := health.NewChecker(time.Minute, time.Minute)
+		c.Add("database", func(ctx context.Context) error {
+			close(started)
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		first := make(chan health.Report)
+		go func() { first <- c.Ready(ctx) }()
+		<-started
+
+		cancel()
+		assert.Equal(t, health.StatusFail, (<-first).Status)
+
+		second := make(chan health.Report)
+		go func() { second <- c.Ready(context.Background()) }()
+		close(release)
+
+		report := <-second
+		assert.Equal(t, health.StatusOK, report.Status, report)
+		assert.Equal(t, health.StatusOK, c.Ready(context.Background()).Status, "the cached report")
+	})
+
+	t.Run("should fail when the service is marked as not ready", func(t *testing.T) {
+		c := health.NewChecker(time.Second, time.Minute)
+		c.Add("database", func(ctx context.Context) error { return nil })
+		c.SetReady(false)
+
+		code, report := ready(t, c)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.StatusFail, report.Status)
+	})
+}
+
+func TestUserCheck(t *testing.T) {
+
+	t.Run("should pass when the user service answers not found", func(t *testing.T) {
+		check := health.UserCheck(&mockUserSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, userSdk.ErrNotFound{Message: "user not found"}
+			},
+		})
+		assert.NoError(t, check(context.Background()))
+	})
+
+	t.Run("should request a well-formed id", func(t *testing.T) {
+		var got string
+		check := health.UserCheck(&mockUserSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				got = id
+				return nil, userSdk.ErrNotFound{Message: "user not found"}
+			},
+		})
+		require.NoError(t, check(context.Background()))
+
+		_, err := uuid.Parse(got)
+		assert.NoError(t, err, got)
+	})
+
+	t.Run("should pass the deadline to a transport that takes a context", func(t *testing.T) {
+		var deadline bool
+		check := health.UserCheck(contextUserTrans(func(ctx context.Context, id string) (*domain.User, error) {
+			_, deadline = ctx.Deadline()
+			return nil, userSdk.ErrNotFound{Message: "user not found"}
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, check(ctx))
+		assert.True(t, deadline)
+	})
+
+	t.Run("should fail when the user service can't be reached", func(t *testing.T) {
+		check := health.UserCheck(&mockUserSdk.UserSdkMock{
+			GetMock: func(id string) (*domain.User, error) {
+				return nil, errors.New("connection refused")
+			},
+		})
+		assert.EqualError(t, check(context.Background()), "connection refused")
+	})
+}
+
+// contextUserTrans is a user transport that takes the context of the call.
+type contextUserTrans func(ctx context.Context, id string) (*domain.User, error)
+
+func (f contextUserTrans) Get(id string) (*domain.User, error) {
+	return f(context.Background(), id)
+}
+
+func (f contextUserTrans) GetContext(ctx context.Context, id string) (*domain.User, error) {
+	return f(ctx, id)
+}
@@ -0,0 +1,46 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ncostamagna/gocourse_enrollment/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+
+	t.Run("should write json records with the request id", func(t *testing.T) {
+		var buf bytes.Buffer
+		l, err := logger.New(&buf, "info")
+		require.NoError(t, err)
+
+		ctx := logger.WithRequestID(context.Background(), "req-1")
+		l.With("component", "service").InfoContext(ctx, "enrollment created", "enrollment_id", "10010")
+
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "INFO", record["level"])
+		assert.Equal(t, "enrollment created", record["msg"])
+		assert.Equal(t, "req-1", record["request_id"])
+		assert.Equal(t, "service", record["component"])
+		assert.Equal(t, "10010", record["enrollment_id"])
+	})
+
+	t.Run("should skip records below the level", func(t *testing.T) {
+		var buf bytes.Buffer
+		l, err := logger.New(&buf, "warn")
+		require.NoError(t, err)
+
+		l.Info("ignored")
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("should return an error with an invalid level", func(t *testing.T) {
+		_, err := logger.New(&bytes.Buffer{}, "verbose")
+		assert.EqualError(t, err, "invalid log level 'verbose'")
+	})
+}
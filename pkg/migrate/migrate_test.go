@@ -0,0 +1,257 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// registers the database/sql driver named sqlite
+	_ "github.com/glebarez/sqlite"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+
+	t.Run("should load the mysql migrations in order", func(t *testing.T) {
+		migrations, err := migrate.Migrations("mysql")
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, len(migrations), 4)
+
+		for i, m := range migrations {
+			assert.Equal(t, i+1, m.Version)
+			assert.NotEmpty(t, m.Up)
+			assert.NotEmpty(t, m.Down)
+		}
+
+		assert.Equal(t, "create_enrollments", migrations[0].Name)
+		assert.Equal(t, "add_enrollments_indexes", migrations[1].Name)
+		for _, column := range []string{"user_id", "course_id", "status"} {
+			assert.Contains(t, migrations[1].Up, "idx_enrollments_"+column)
+		}
+		assert.Equal(t, "create_enrollment_progress", migrations[2].Name)
+		assert.Equal(t, "create_certificates", migrations[3].Name)
+		assert.Equal(t, "add_enrollments_user_course_unique", migrations[4].Name)
+		assert.Equal(t, "alter_enrollments_status_varchar", migrations[5].Name)
+	})
+
+	t.Run("should return an error with an unknown driver", func(t *testing.T) {
+		_, err := migrate.Migrations("oracle")
+		assert.EqualError(t, err, "no migrations for driver 'oracle'")
+
+		_, err = migrate.New(nil, "oracle")
+		assert.EqualError(t, err, "migrations aren't supported for driver 'oracle'")
+	})
+}
+
+func TestMergeDuplicatedEnrollments(t *testing.T) {
+	ctx := context.Background()
+
+	db := newSQLiteDB(t)
+	m, err := migrate.New(db, "sqlite")
+	require.NoError(t, err)
+	all, err := migrate.Migrations("sqlite")
+	require.NoError(t, err)
+
+	// the database as it was before the unique index
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, len(all)-4)
+	require.NoError(t, err)
+	require.Equal(t, versions(all[:4]), applied(t, m))
+
+	for _, stmt := range []string{
+		`INSERT INTO enrollments (id, user_id, course_id, status, created_at) VALUES
+			('e-1', 'u-1', 'c-1', 'P', '2024-01-01 00:00:00'),
+			('e-2', 'u-1', 'c-1', 'C', '2024-01-02 00:00:00'),
+			('e-3', 'u-1', 'c-1', 'S', '2024-01-03 00:00:00'),
+			('e-4', 'u-2', 'c-1', 'P', '2024-01-02 00:00:00')`,
+		`INSERT INTO enrollment_progress (enrollment_id, total_lessons) VALUES ('e-2', 2), ('e-3', 3)`,
+		`INSERT INTO enrollment_lessons (enrollment_id, lesson_id, completed_at) VALUES
+			('e-2', 'l-1', '2024-01-05 00:00:00'),
+			('e-2', 'l-2', '2024-01-06 00:00:00'),
+			('e-3', 'l-1', '2024-01-04 00:00:00')`,
+		`INSERT INTO certificates (enrollment_id, code, user_id, course_id, student_name, course_title, issued_at) VALUES
+			('e-2', '7KQ2-M9XD-4HRT-0CWV', 'u-1', 'c-1', 'Nahuel', 'Go', '2024-01-06 00:00:00')`,
+	} {
+		_, err := db.ExecContext(ctx, stmt)
+		require.NoError(t, err)
+	}
+
+	done, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, versions(all[4:]), versions(done))
+
+	assert.Equal(t, []string{"e-1 C", "e-4 P"}, query(t, db, "SELECT id || ' ' || status FROM enrollments ORDER BY id"))
+	assert.Equal(t, []string{"e-1 l-1 2024-01-04 00:00:00", "e-1 l-2 2024-01-06 00:00:00"},
+		query(t, db, "SELECT enrollment_id || ' ' || lesson_id || ' ' || completed_at FROM enrollment_lessons ORDER BY lesson_id"))
+	assert.Equal(t, []string{"e-1 3"}, query(t, db, "SELECT enrollment_id || ' ' || total_lessons FROM enrollment_progress"))
+	assert.Equal(t, []string{"e-1 7KQ2-M9XD-4HRT-0CWV"}, query(t, db, "SELECT enrollment_id || ' ' || code FROM certificates"))
+	assert.NotContains(t, tables(t, db), "migrate_enrollment_duplicates")
+	assert.NotContains(t, tables(t, db), "migrate_certificate_moves")
+
+	_, err = db.ExecContext(ctx, `INSERT INTO enrollments (id, user_id, course_id, status) VALUES ('e-5', 'u-1', 'c-1', 'P')`)
+	assert.ErrorContains(t, err, "UNIQUE constraint failed")
+}
+
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "enrollments.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Err())
+	return names
+}
+
+// query returns the first column of the rows as strings.
+func query(t *testing.T, db *sql.DB, q string) []string {
+	t.Helper()
+
+	rows, err := db.Query(q)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		require.NoError(t, rows.Scan(&v))
+		values = append(values, v)
+	}
+	require.NoError(t, rows.Err())
+	return values
+}
+
+func versions(migrations []migrate.Migration) []int {
+	v := make([]int, len(migrations))
+	for i, m := range migrations {
+		v[i] = m.Version
+	}
+	return v
+}
+
+// applied returns the versions Status reports as applied.
+func applied(t *testing.T, m *migrate.Migrator) []int {
+	t.Helper()
+
+	status, err := m.Status(context.Background())
+	require.NoError(t, err)
+
+	var v []int
+	for _, s := range status {
+		if s.AppliedAt != nil {
+			v = append(v, s.Version)
+		}
+	}
+	return v
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	all, err := migrate.Migrations("sqlite")
+	require.NoError(t, err)
+	n := len(all)
+	require.GreaterOrEqual(t, n, 4)
+
+	db := newSQLiteDB(t)
+	m, err := migrate.New(db, "sqlite")
+	require.NoError(t, err)
+
+	t.Run("should report every migration as pending on a new database", func(t *testing.T) {
+		status, err := m.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, status, n)
+		for i, s := range status {
+			assert.Equal(t, all[i].Version, s.Version)
+			assert.Equal(t, all[i].Name, s.Name)
+			assert.Nil(t, s.AppliedAt)
+		}
+	})
+
+	t.Run("should apply every migration in order", func(t *testing.T) {
+		done, err := m.Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, versions(all), versions(done))
+		assert.Equal(t, versions(all), applied(t, m))
+
+		assert.Subset(t, tables(t, db), []string{"schema_migrations", "enrollments", "enrollment_progress", "enrollment_lessons", "certificates"})
+	})
+
+	t.Run("should do nothing when it's run again", func(t *testing.T) {
+		before, err := m.Status(ctx)
+		require.NoError(t, err)
+
+		done, err := m.Up(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, done)
+
+		after, err := m.Status(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("should roll back the last steps", func(t *testing.T) {
+		done, err := m.Down(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []int{all[n-1].Version, all[n-2].Version}, versions(done))
+		assert.Equal(t, versions(all[:n-2]), applied(t, m))
+	})
+
+	t.Run("should apply the rolled back migrations again", func(t *testing.T) {
+		done, err := m.Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, versions(all[n-2:]), versions(done))
+		assert.Equal(t, versions(all), applied(t, m))
+	})
+
+	t.Run("should roll back every migration when the steps exceed them", func(t *testing.T) {
+		done, err := m.Down(ctx, n+10)
+		require.NoError(t, err)
+		assert.Len(t, done, n)
+		assert.Empty(t, applied(t, m))
+		assert.Equal(t, []string{"schema_migrations"}, tables(t, db))
+	})
+}
+
+func TestMigratorOneConnection(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	db := newSQLiteDB(t)
+	db.SetMaxOpenConns(1)
+	m, err := migrate.New(db, "sqlite")
+	require.NoError(t, err)
+
+	t.Run("should run every migration on the locked connection", func(t *testing.T) {
+		_, err := m.Up(ctx)
+		require.NoError(t, err)
+
+		_, err = m.Down(ctx, 1)
+		require.NoError(t, err)
+
+		status, err := m.Status(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, status[len(status)-1].AppliedAt)
+	})
+}
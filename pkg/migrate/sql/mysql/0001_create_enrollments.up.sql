@@ -0,0 +1,10 @@
+-- Baseline: same table GORM AutoMigrate used to create, so existing databases are kept.
+CREATE TABLE IF NOT EXISTS enrollments (
+  id char(36) NOT NULL,
+  user_id char(36) DEFAULT NULL,
+  course_id char(36) NOT NULL,
+  status char(2) DEFAULT NULL,
+  created_at datetime(3) DEFAULT NULL,
+  updated_at datetime(3) DEFAULT NULL,
+  PRIMARY KEY (id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX idx_enrollments_status ON enrollments;
+DROP INDEX idx_enrollments_course_id ON enrollments;
+DROP INDEX idx_enrollments_user_id ON enrollments;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS enrollment_progress (
+  enrollment_id char(36) NOT NULL,
+  total_lessons int NOT NULL,
+  last_activity_at datetime(3) DEFAULT NULL,
+  PRIMARY KEY (enrollment_id)
+);
+CREATE TABLE IF NOT EXISTS enrollment_lessons (
+  enrollment_id char(36) NOT NULL,
+  lesson_id char(36) NOT NULL,
+  completed_at datetime(3) NOT NULL,
+  PRIMARY KEY (enrollment_id, lesson_id)
+);
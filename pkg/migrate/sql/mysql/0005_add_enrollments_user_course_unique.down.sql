@@ -0,0 +1 @@
+DROP INDEX idx_enrollments_user_course ON enrollments;
@@ -0,0 +1 @@
+ALTER TABLE enrollments MODIFY status char(2) DEFAULT NULL;
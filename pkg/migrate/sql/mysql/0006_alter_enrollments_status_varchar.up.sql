@@ -0,0 +1 @@
+ALTER TABLE enrollments MODIFY status varchar(2) DEFAULT NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS enrollments (
+  id char(36) NOT NULL,
+  user_id char(36) DEFAULT NULL,
+  course_id char(36) NOT NULL,
+  status varchar(2) DEFAULT NULL,
+  created_at timestamptz DEFAULT NULL,
+  updated_at timestamptz DEFAULT NULL,
+  PRIMARY KEY (id)
+);
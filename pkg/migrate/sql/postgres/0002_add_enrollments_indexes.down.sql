@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_enrollments_status;
+DROP INDEX IF EXISTS idx_enrollments_course_id;
+DROP INDEX IF EXISTS idx_enrollments_user_id;
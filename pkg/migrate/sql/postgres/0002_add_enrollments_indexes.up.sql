@@ -0,0 +1,3 @@
+CREATE INDEX idx_enrollments_user_id ON enrollments (user_id);
+CREATE INDEX idx_enrollments_course_id ON enrollments (course_id);
+CREATE INDEX idx_enrollments_status ON enrollments (status);
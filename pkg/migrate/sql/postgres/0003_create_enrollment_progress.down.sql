@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS enrollment_lessons;
+DROP TABLE IF EXISTS enrollment_progress;
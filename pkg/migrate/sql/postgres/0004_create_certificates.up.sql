@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS certificates (
+  enrollment_id char(36) NOT NULL,
+  code char(19) NOT NULL,
+  user_id char(36) NOT NULL,
+  course_id char(36) NOT NULL,
+  student_name varchar(101) NOT NULL,
+  course_title varchar(50) NOT NULL,
+  issued_at timestamptz NOT NULL,
+  PRIMARY KEY (enrollment_id)
+);
+CREATE UNIQUE INDEX idx_certificates_code ON certificates (code);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_enrollments_user_course;
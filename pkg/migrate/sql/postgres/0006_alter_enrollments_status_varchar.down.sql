@@ -0,0 +1 @@
+ALTER TABLE enrollments ALTER COLUMN status TYPE char(2);
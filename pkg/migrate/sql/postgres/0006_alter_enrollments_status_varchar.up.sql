@@ -0,0 +1,3 @@
+-- char(2) pads the one letter statuses with a blank on read, the databases
+-- created by GORM or by an earlier 0001 still have it.
+ALTER TABLE enrollments ALTER COLUMN status TYPE varchar(2) USING rtrim(status);
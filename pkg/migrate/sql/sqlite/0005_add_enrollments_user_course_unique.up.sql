@@ -0,0 +1,56 @@
+-- Merges the duplicated enrollments of a user in a course into the oldest
+-- one before the unique index: their lessons, progress and a certificate move
+-- to it, and it becomes completed when one of them was. Their other
+-- certificates are kept so the codes still verify.
+DROP TABLE IF EXISTS migrate_enrollment_duplicates;
+DROP TABLE IF EXISTS migrate_certificate_moves;
+CREATE TABLE migrate_enrollment_duplicates (
+  id char(36) NOT NULL,
+  status char(2) DEFAULT NULL,
+  keep_id char(36) DEFAULT NULL
+);
+INSERT INTO migrate_enrollment_duplicates (id, status, keep_id)
+SELECT e.id, e.status, (
+  SELECT k.id FROM enrollments k
+  WHERE k.user_id = e.user_id AND k.course_id = e.course_id
+  ORDER BY k.created_at, k.id
+  LIMIT 1
+) AS keep_id
+FROM enrollments e;
+DELETE FROM migrate_enrollment_duplicates WHERE keep_id IS NULL OR keep_id = id;
+INSERT INTO enrollment_lessons (enrollment_id, lesson_id, completed_at)
+SELECT d.keep_id, l.lesson_id, MIN(l.completed_at)
+FROM enrollment_lessons l
+JOIN migrate_enrollment_duplicates d ON d.id = l.enrollment_id
+WHERE NOT EXISTS (
+  SELECT 1 FROM enrollment_lessons k WHERE k.enrollment_id = d.keep_id AND k.lesson_id = l.lesson_id
+)
+GROUP BY d.keep_id, l.lesson_id;
+INSERT INTO enrollment_progress (enrollment_id, total_lessons, last_activity_at)
+SELECT d.keep_id, MAX(p.total_lessons), MAX(p.last_activity_at)
+FROM enrollment_progress p
+JOIN migrate_enrollment_duplicates d ON d.id = p.enrollment_id
+WHERE NOT EXISTS (SELECT 1 FROM enrollment_progress k WHERE k.enrollment_id = d.keep_id)
+GROUP BY d.keep_id;
+CREATE TABLE migrate_certificate_moves (
+  enrollment_id char(36) NOT NULL,
+  keep_id char(36) NOT NULL
+);
+INSERT INTO migrate_certificate_moves (enrollment_id, keep_id)
+SELECT MIN(c.enrollment_id) AS enrollment_id, d.keep_id
+FROM certificates c
+JOIN migrate_enrollment_duplicates d ON d.id = c.enrollment_id
+WHERE NOT EXISTS (SELECT 1 FROM certificates k WHERE k.enrollment_id = d.keep_id)
+GROUP BY d.keep_id;
+UPDATE certificates SET enrollment_id = (
+  SELECT m.keep_id FROM migrate_certificate_moves m WHERE m.enrollment_id = certificates.enrollment_id
+)
+WHERE enrollment_id IN (SELECT enrollment_id FROM migrate_certificate_moves);
+UPDATE enrollments SET status = 'C'
+WHERE id IN (SELECT keep_id FROM migrate_enrollment_duplicates WHERE status = 'C');
+DELETE FROM enrollment_lessons WHERE enrollment_id IN (SELECT id FROM migrate_enrollment_duplicates);
+DELETE FROM enrollment_progress WHERE enrollment_id IN (SELECT id FROM migrate_enrollment_duplicates);
+DELETE FROM enrollments WHERE id IN (SELECT id FROM migrate_enrollment_duplicates);
+DROP TABLE migrate_certificate_moves;
+DROP TABLE migrate_enrollment_duplicates;
+CREATE UNIQUE INDEX idx_enrollments_user_course ON enrollments (user_id, course_id);
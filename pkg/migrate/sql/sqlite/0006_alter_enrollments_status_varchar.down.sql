@@ -0,0 +1 @@
+-- sqlite doesn't pad char columns, the status is kept as it is.
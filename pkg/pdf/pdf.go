@@ -0,0 +1,118 @@
+// Package pdf writes single page PDF documents with text, lines and
+// rectangles. It only uses the standard Helvetica fonts, which the readers
+// provide, so nothing is embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Font is a standard font of the page.
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+// Sizes of the pages in points, 1/72 of an inch.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+var fonts = []string{Helvetica: "Helvetica", HelveticaBold: "Helvetica-Bold"}
+
+// Page is a page being drawn. The origin is the bottom left corner.
+type Page struct {
+	width, height float64
+	content       bytes.Buffer
+}
+
+// NewPage returns an empty page, swap A4Width and A4Height for landscape.
+func NewPage(width, height float64) *Page {
+	return &Page{width: width, height: height}
+}
+
+// Text writes s starting at x with its baseline at y. The characters out of
+// Latin-1 are written as '?'.
+func (p *Page) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		font+1, num(size), num(x), num(y), escape(s))
+}
+
+// Line draws a line of the given width.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+// Rect draws the border of a rectangle, its bottom left corner at x, y.
+func (p *Page) Rect(x, y, w, h, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s %s %s re S\n", num(width), num(x), num(y), num(w), num(h))
+}
+
+// Bytes returns the document holding the page.
+func (p *Page) Bytes() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Contents 4 0 R /Resources << /Font << %s >> >> >>",
+			num(p.width), num(p.height), fontResources()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+	}
+	for _, name := range fonts {
+		objects = append(objects, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+
+	var b bytes.Buffer
+	// the binary comment tells the transfer tools the file isn't text
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return b.Bytes()
+}
+
+// fontResources names the fonts F1, F2... after the page objects.
+func fontResources() string {
+	res := make([]string, len(fonts))
+	for i := range fonts {
+		res[i] = fmt.Sprintf("/F%d %d 0 R", i+1, i+5)
+	}
+	return strings.Join(res, " ")
+}
+
+// escape encodes s in WinAnsi, which matches Latin-1 for the printable
+// characters, and escapes the delimiters of a string.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f || r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func num(f float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", f), "0"), ".")
+}
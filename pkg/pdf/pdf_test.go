@@ -0,0 +1,52 @@
+package pdf_test
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/ncostamagna/gocourse_enrollment/pkg/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPage(t *testing.T) {
+
+	t.Run("should write a document with the page content", func(t *testing.T) {
+		p := pdf.NewPage(pdf.A4Height, pdf.A4Width)
+		p.Text(72, 500, pdf.HelveticaBold, 24.5, "Certificate")
+		p.Rect(20, 20, 100, 50, 1)
+		b := p.Bytes()
+
+		assert.True(t, bytes.HasPrefix(b, []byte("%PDF-1.4\n")))
+		assert.True(t, bytes.HasSuffix(b, []byte("%%EOF\n")))
+		assert.Contains(t, string(b), "/MediaBox [0 0 841.89 595.28]")
+		assert.Contains(t, string(b), "BT /F2 24.5 Tf 72 500 Td (Certificate) Tj ET\n")
+		assert.Contains(t, string(b), "1 w 20 20 100 50 re S\n")
+		assert.Contains(t, string(b), "/BaseFont /Helvetica-Bold")
+	})
+
+	t.Run("should point the cross reference table to every object", func(t *testing.T) {
+		b := pdf.NewPage(pdf.A4Width, pdf.A4Height).Bytes()
+
+		m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(b)
+		require.NotNil(t, m)
+		xref, _ := strconv.Atoi(string(m[1]))
+		require.True(t, bytes.HasPrefix(b[xref:], []byte("xref\n0 7\n")))
+
+		offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(b[xref:], -1)
+		require.Len(t, offsets, 6)
+		for i, off := range offsets {
+			n, _ := strconv.Atoi(string(off[1]))
+			assert.True(t, bytes.HasPrefix(b[n:], []byte(strconv.Itoa(i+1)+" 0 obj\n")), "object %d", i+1)
+		}
+	})
+
+	t.Run("should escape the text", func(t *testing.T) {
+		p := pdf.NewPage(pdf.A4Width, pdf.A4Height)
+		p.Text(0, 0, pdf.Helvetica, 12, `José (\) 日`)
+
+		assert.Contains(t, string(p.Bytes()), "(Jos\xe9 \\(\\\\\\) ?) Tj")
+	})
+}
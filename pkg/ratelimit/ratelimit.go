@@ -0,0 +1,229 @@
+// Package ratelimit implements token bucket rate limiting with pluggable
+// bucket storage.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ForwardedForHeader lists the addresses a request went through, each proxy
+// appends the address it got the request from.
+const ForwardedForHeader = "X-Forwarded-For"
+
+type (
+	// Limit allows Requests every Period with bursts of up to Burst requests.
+	// Burst defaults to Requests.
+	Limit struct {
+		Requests int           `yaml:"requests"`
+		Period   time.Duration `yaml:"period"`
+		Burst    int           `yaml:"burst"`
+	}
+
+	Result struct {
+		Allowed bool
+		// Limit is the capacity of the bucket and Remaining the tokens left.
+		Limit     int
+		Remaining int
+		// RetryAfter is the wait until the next token when the request isn't
+		// allowed, and Reset the wait until the bucket is full again.
+		RetryAfter time.Duration
+		Reset      time.Duration
+	}
+
+	// Store keeps the buckets. Take must take a token from the bucket of the
+	// key atomically, so the limits hold across the instances sharing a store.
+	Store interface {
+		Take(ctx context.Context, key string, limit Limit) (Result, error)
+	}
+
+	// KeyFunc identifies the client of the request.
+	KeyFunc func(r *http.Request) string
+
+	bucket struct {
+		tokens float64
+		last   time.Time
+		limit  Limit
+	}
+
+	// MemoryStore keeps the buckets in the process.
+	MemoryStore struct {
+		mu        sync.Mutex
+		buckets   map[string]*bucket
+		lastSweep time.Time
+	}
+)
+
+// sweepInterval is how often the full buckets, which are the same as a
+// missing one, are removed from the memory store.
+const sweepInterval = time.Minute
+
+// Enabled reports whether the limit allows a finite rate.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// rate is the number of tokens added per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.burst()), last: now, limit: limit}
+		s.buckets[key] = b
+	}
+
+	return b.take(now), nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.refill(now) >= float64(b.limit.burst()) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (b *bucket) refill(now time.Time) float64 {
+	return math.Min(float64(b.limit.burst()), b.tokens+now.Sub(b.last).Seconds()*b.limit.rate())
+}
+
+func (b *bucket) take(now time.Time) Result {
+	b.tokens = b.refill(now)
+	b.last = now
+
+	res := Result{Limit: b.limit.burst()}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / b.limit.rate())
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(res.Limit) - b.tokens) / b.limit.rate())
+
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ClientKey identifies the client by its IP address, an IPv6 client by its
+// /64 network, which it can easily change addresses in. The service doesn't
+// authenticate its clients, so a header they send, like an API key, can't
+// pick the bucket: a client would get a new one on every request.
+func ClientKey(r *http.Request) string {
+	return ipKey(remoteAddr(r))
+}
+
+// ProxyClientKey identifies the client like ClientKey, but when the request
+// comes from one of the trusted proxies the address is taken from
+// X-Forwarded-For. The client is the last address that isn't a trusted
+// proxy, the addresses before it are sent by the client and can be forged.
+func ProxyClientKey(trusted []netip.Prefix) KeyFunc {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) string {
+		addr := remoteAddr(r)
+		if !addr.IsValid() || !isTrusted(addr) {
+			return ipKey(addr)
+		}
+
+		forwarded := strings.Split(strings.Join(r.Header.Values(ForwardedForHeader), ","), ",")
+		for i := len(forwarded) - 1; i >= 0 && isTrusted(addr); i-- {
+			next, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+			if err != nil {
+				// the rest can't be trusted, the last proxy is the client
+				break
+			}
+			addr = next.Unmap()
+		}
+		return ipKey(addr)
+	}
+}
+
+// ParseProxies parses a comma separated list of IP addresses and networks in
+// CIDR notation.
+func ParseProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address '%s'", v)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network '%s'", v)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// remoteAddr returns the address of the peer, the zero address when it can't
+// be parsed.
+func remoteAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, _ := netip.ParseAddr(host)
+	return addr.Unmap()
+}
+
+func ipKey(addr netip.Addr) string {
+	if addr.Is6() {
+		p, _ := addr.Prefix(64)
+		return "ip:" + p.String()
+	}
+	return "ip:" + addr.String()
+}
@@ -0,0 +1,182 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/gocourse_enrollment/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should allow a burst and then deny", func(t *testing.T) {
+		s := ratelimit.NewMemoryStore()
+		limit := ratelimit.Limit{Requests: 1, Period: time.Hour, Burst: 3}
+
+		for want := 2; want >= 0; want-- {
+			res, err := s.Take(ctx, "ip:1", limit)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 3, res.Limit)
+			assert.Equal(t, want, res.Remaining)
+		}
+
+		res, err := s.Take(ctx, "ip:1", limit)
+		require.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Zero(t, res.Remaining)
+		assert.InDelta(t, time.Hour.Seconds(), res.RetryAfter.Seconds(), 1)
+		assert.InDelta(t, (3 * time.Hour).Seconds(), res.Reset.Seconds(), 1)
+	})
+
+	t.Run("should default the burst to the requests", func(t *testing.T) {
+		s := ratelimit.NewMemoryStore()
+
+		res, err := s.Take(ctx, "ip:1", ratelimit.Limit{Requests: 5, Period: time.Minute})
+		require.NoError(t, err)
+		assert.Equal(t, 5, res.Limit)
+		assert.Equal(t, 4, res.Remaining)
+	})
+
+	t.Run("should keep a bucket per key", func(t *testing.T) {
+		s := ratelimit.NewMemoryStore()
+		limit := ratelimit.Limit{Requests: 1, Period: time.Hour}
+
+		res, _ := s.Take(ctx, "ip:1", limit)
+		assert.True(t, res.Allowed)
+		res, _ = s.Take(ctx, "ip:1", limit)
+		assert.False(t, res.Allowed)
+		res, _ = s.Take(ctx, "ip:2", limit)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("should refill the tokens over time", func(t *testing.T) {
+		s := ratelimit.NewMemoryStore()
+		limit := ratelimit.Limit{Requests: 1, Period: 50 * time.Millisecond}
+
+		res, _ := s.Take(ctx, "ip:1", limit)
+		assert.True(t, res.Allowed)
+		res, _ = s.Take(ctx, "ip:1", limit)
+		assert.False(t, res.Allowed)
+
+		time.Sleep(60 * time.Millisecond)
+		res, _ = s.Take(ctx, "ip:1", limit)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("should not allow more than the burst concurrently", func(t *testing.T) {
+		s := ratelimit.NewMemoryStore()
+		limit := ratelimit.Limit{Requests: 10, Period: time.Hour}
+
+		var mu sync.Mutex
+		var allowed int
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, _ := s.Take(ctx, "ip:1", limit)
+				if res.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 10, allowed)
+	})
+}
+
+func TestClientKey(t *testing.T) {
+
+	obj := []struct {
+		tag        string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{tag: "should use the ip", remoteAddr: "192.0.2.1:1234", want: "ip:192.0.2.1"},
+		{tag: "should ignore the headers the client picks", remoteAddr: "192.0.2.1:1234",
+			headers: map[string]string{"X-API-Key": "k1", "X-User-ID": "u1", "X-Forwarded-For": "198.51.100.7"}, want: "ip:192.0.2.1"},
+		{tag: "should group an ipv6 client by its network", remoteAddr: "[2001:db8:1:2:3:4:5:6]:1234", want: "ip:2001:db8:1:2::/64"},
+		{tag: "should unmap an ipv4 address", remoteAddr: "[::ffff:192.0.2.1]:1234", want: "ip:192.0.2.1"},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/enrollments", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, ratelimit.ClientKey(req))
+		})
+	}
+}
+
+func TestProxyClientKey(t *testing.T) {
+
+	trusted, err := ratelimit.ParseProxies("10.0.0.0/8, 192.0.2.1")
+	require.NoError(t, err)
+	key := ratelimit.ProxyClientKey(trusted)
+
+	obj := []struct {
+		tag          string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{tag: "should take the client from a trusted proxy", remoteAddr: "10.0.0.5:1234",
+			forwardedFor: []string{"198.51.100.7"}, want: "ip:198.51.100.7"},
+		{tag: "should skip the trusted proxies", remoteAddr: "10.0.0.5:1234",
+			forwardedFor: []string{"198.51.100.7, 192.0.2.1", "10.1.2.3"}, want: "ip:198.51.100.7"},
+		{tag: "should ignore the addresses forged by the client", remoteAddr: "10.0.0.5:1234",
+			forwardedFor: []string{"203.0.113.9, 198.51.100.7"}, want: "ip:198.51.100.7"},
+		{tag: "should ignore the header from an untrusted peer", remoteAddr: "198.51.100.7:1234",
+			forwardedFor: []string{"203.0.113.9"}, want: "ip:198.51.100.7"},
+		{tag: "should use the proxy without the header", remoteAddr: "10.0.0.5:1234", want: "ip:10.0.0.5"},
+		{tag: "should stop at a malformed address", remoteAddr: "10.0.0.5:1234",
+			forwardedFor: []string{"203.0.113.9, unknown"}, want: "ip:10.0.0.5"},
+	}
+
+	for _, tt := range obj {
+		t.Run(tt.tag, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/enrollments", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			assert.Equal(t, tt.want, key(req))
+		})
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+
+	t.Run("should parse the addresses and the networks", func(t *testing.T) {
+		got, err := ratelimit.ParseProxies("10.1.2.3/8,192.0.2.1, 2001:db8::/32,")
+		require.NoError(t, err)
+		assert.Equal(t, []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.0.2.1/32"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		}, got)
+	})
+
+	t.Run("should return an error with an invalid value", func(t *testing.T) {
+		_, err := ratelimit.ParseProxies("10.0.0.0/8,proxy")
+		assert.EqualError(t, err, "invalid address 'proxy'")
+
+		_, err = ratelimit.ParseProxies("10.0.0.0/40")
+		assert.EqualError(t, err, "invalid network '10.0.0.0/40'")
+	})
+}
@@ -0,0 +1,120 @@
+// Package sdk calls the user and course services the way the go_course_sdk
+// HTTP clients do, and returns the same errors. Unlike them its clients take
+// the context of the call, which cancels the request and carries the W3C
+// trace context to the services, and they're safe for concurrent use.
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/internal/enrollment"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	courseSdk "github.com/ncostamagna/go_course_sdk/course"
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
+)
+
+// connectTimeout is the connection timeout of the SDK clients.
+const connectTimeout = 5 * time.Second
+
+type (
+	client[T any] struct {
+		http     *http.Client
+		baseURL  string
+		resource string
+		notFound func(msg string) error
+	}
+
+	// envelope is the body of the user and course services.
+	envelope struct {
+		Message string      `json:"message"`
+		Data    interface{} `json:"data"`
+	}
+
+	userClient struct{ client[domain.User] }
+
+	courseClient struct{ client[domain.Course] }
+)
+
+// NewUserClient returns a client of the user service at baseURL, every call
+// fails after the timeout.
+func NewUserClient(baseURL string, timeout time.Duration) enrollment.UserTransport {
+	return userClient{newClient[domain.User](baseURL, "users", timeout, func(msg string) error {
+		return userSdk.ErrNotFound{Message: msg}
+	})}
+}
+
+// NewCourseClient is the same as NewUserClient for the course service.
+func NewCourseClient(baseURL string, timeout time.Duration) enrollment.CourseTransport {
+	return courseClient{newClient[domain.Course](baseURL, "courses", timeout, func(msg string) error {
+		return courseSdk.ErrNotFound{Message: msg}
+	})}
+}
+
+func newClient[T any](baseURL, resource string, timeout time.Duration, notFound func(string) error) client[T] {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = (&net.Dialer{Timeout: connectTimeout}).DialContext
+
+	return client[T]{
+		http:     &http.Client{Transport: tr, Timeout: timeout},
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		resource: resource,
+		notFound: notFound,
+	}
+}
+
+func (c userClient) Get(id string) (*domain.User, error) {
+	return c.GetContext(context.Background(), id)
+}
+
+func (c courseClient) Get(id string) (*domain.Course, error) {
+	return c.GetContext(context.Background(), id)
+}
+
+// GetContext returns the record with the id, the service answers not found
+// with the ErrNotFound of the SDK and any other failure with its message, or
+// its status when it has none.
+func (c client[T]) GetContext(ctx context.Context, id string) (*T, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/%s/%s", c.baseURL, c.resource, url.PathEscape(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		// the failures of a proxy have no JSON body
+		var body envelope
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		if body.Message == "" {
+			body.Message = fmt.Sprintf("%s service answered %s", strings.TrimSuffix(c.resource, "s"), resp.Status)
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, c.notFound(body.Message)
+		}
+		return nil, errors.New(body.Message)
+	}
+
+	data := new(T)
+	if err := json.NewDecoder(resp.Body).Decode(&envelope{Data: data}); err != nil {
+		return nil, fmt.Errorf("decoding the %s response: %w", c.resource, err)
+	}
+	return data, nil
+}
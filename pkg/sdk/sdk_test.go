@@ -0,0 +1,124 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncostamagna/gocourse_domain/domain"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/fakeapi"
+	"github.com/ncostamagna/gocourse_enrollment/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	courseSdk "github.com/ncostamagna/go_course_sdk/course"
+	userSdk "github.com/ncostamagna/go_course_sdk/user"
+)
+
+func TestClients(t *testing.T) {
+	srv := fakeapi.New()
+	defer srv.Close()
+
+	srv.AddUsers(domain.User{ID: "1", FirstName: "Nahuel", Email: "nahuel@mail.com"})
+	srv.AddCourses(domain.Course{ID: "4", Name: "Go"})
+
+	users := sdk.NewUserClient(srv.URL, 5*time.Second)
+	courses := sdk.NewCourseClient(srv.URL+"/", 5*time.Second)
+
+	t.Run("should return the records", func(t *testing.T) {
+		u, err := users.Get("1")
+		require.NoError(t, err)
+		assert.Equal(t, "Nahuel", u.FirstName)
+		assert.Equal(t, "nahuel@mail.com", u.Email)
+
+		c, err := courses.GetContext(context.Background(), "4")
+		require.NoError(t, err)
+		assert.Equal(t, "Go", c.Name)
+	})
+
+	t.Run("should return the not found errors of the sdk", func(t *testing.T) {
+		_, err := users.Get("2")
+		assert.Equal(t, userSdk.ErrNotFound{Message: "user '2' doesn't exist"}, err)
+
+		_, err = courses.Get("2")
+		assert.Equal(t, courseSdk.ErrNotFound{Message: "course '2' doesn't exist"}, err)
+	})
+
+	t.Run("should return the message of a failure", func(t *testing.T) {
+		t.Cleanup(srv.ClearFaults)
+		srv.SetFault(fakeapi.Courses, "", fakeapi.Fault{StatusCode: 500, Message: "database down"})
+
+		_, err := courses.Get("4")
+		assert.EqualError(t, err, "database down")
+	})
+
+	t.Run("should return the status of a failure without a message", func(t *testing.T) {
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		}))
+		defer proxy.Close()
+
+		_, err := sdk.NewUserClient(proxy.URL, 5*time.Second).Get("1")
+		assert.EqualError(t, err, "user service answered 502 Bad Gateway")
+
+		_, err = sdk.NewCourseClient(proxy.URL, 5*time.Second).Get("4")
+		assert.EqualError(t, err, "course service answered 502 Bad Gateway")
+	})
+
+	t.Run("should give up after the timeout", func(t *testing.T) {
+		t.Cleanup(srv.ClearFaults)
+		srv.SetFault(fakeapi.Users, "", fakeapi.Fault{Latency: 200 * time.Millisecond})
+
+		start := time.Now()
+		_, err := sdk.NewUserClient(srv.URL, 20*time.Millisecond).Get("1")
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err), "unexpected error: %v", err)
+		assert.Less(t, time.Since(start), 200*time.Millisecond)
+	})
+
+	t.Run("should stop when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := users.GetContext(ctx, "1")
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	})
+
+	t.Run("should send the trace context", func(t *testing.T) {
+		prev := otel.GetTextMapPropagator()
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+		}))
+
+		_, err := users.GetContext(ctx, "1")
+		require.NoError(t, err)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			srv.Header(fakeapi.Users, "1").Get("traceparent"))
+	})
+
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := users.Get("1")
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	})
+}